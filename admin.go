@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"essay-go/config"
+	"essay-go/services"
+)
+
+// runCommand 执行管理命令，执行完毕后程序退出，不启动服务器
+func runCommand(cfg *config.Config, name string, args []string) {
+	var err error
+	switch name {
+	case "reset-table":
+		err = resetTable(cfg, args)
+	default:
+		err = fmt.Errorf("未知的命令: %s（可用命令: reset-table）", name)
+	}
+	if err != nil {
+		log.Fatalf("%s 执行失败: %v", name, err)
+	}
+	log.Printf("%s 执行完成", name)
+}
+
+// resetTable 删除并重建 DynamoDB 作文表，所有用户的作文都会被清空
+//
+// 用法: essay-go reset-table -confirm <表名>。必须再次输入表名确认，生产环境（GIN_MODE=release）拒绝执行。
+func resetTable(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("reset-table", flag.ContinueOnError)
+	confirm := flags.String("confirm", "", "再次输入要清空的表名以确认")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *confirm != cfg.DynamoDBTable {
+		return fmt.Errorf("此操作会清空表 %s 中的所有作文，请使用 -confirm %s 确认", cfg.DynamoDBTable, cfg.DynamoDBTable)
+	}
+
+	log.Printf("正在重置 DynamoDB 表 %s（区域: %s）...", cfg.DynamoDBTable, cfg.AWSRegion)
+	return services.ResetDynamoDBTable(cfg.AWSRegion, cfg.DynamoDBTable, cfg.Production)
+}
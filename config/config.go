@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config 应用配置结构
@@ -13,6 +15,50 @@ type Config struct {
 	AIKey          string
 	DeepSeekModel  string
 	DeepSeekAPIKey string
+	// OpenAI 兼容服务配置（例如本地 Ollama）
+	OpenAIEndpoint string
+	OpenAIAPIKey   string
+	OpenAIModel    string
+	// AI服务提供方回退链，逗号分隔: auto 或 deepseek、openai、legacy、mock 的组合
+	AIProvider string
+	// 提供方失败后的冷却时间，冷却期内优先使用其他提供方
+	AIProviderCooldown time.Duration
+	// 单次润色请求（含重试和回退）的截止时间
+	AIRequestTimeout time.Duration
+	// 外部AI调用的重试策略
+	AIRetryMaxAttempts int
+	AIRetryBaseDelay   time.Duration
+	AIRetryMaxDelay    time.Duration
+	// 长作文分段润色：每段的最大字数（0 表示不分段）及相邻段落之间提供的上下文字数
+	AIChunkSize    int
+	AIChunkContext int
+	// 分段润色整篇作文的总截止时间（0 表示不限制），每段仍受 AIRequestTimeout 限制
+	AIChunkTimeout time.Duration
+	// 润色结果缓存的最大条数和有效期（任一为 0 表示不缓存）
+	AICacheSize int
+	AICacheTTL  time.Duration
+	// 提示词模板目录及检查文件变化的间隔（0 表示不自动重新加载）
+	PromptDir            string
+	PromptReloadInterval time.Duration
+	// 作文评分标准文件（JSON）
+	RubricFile string
+	// token用量统计文件及模型价格（"模型=输入价格/输出价格"，单位为元/百万token，逗号分隔）
+	UsageFile     string
+	AITokenPrices string
+	// 润色配额配置文件（JSON，不存在时不限制）及请求计数的保存位置
+	QuotaFile      string
+	QuotaStateFile string
+	// 异步润色任务：保存文件、工作协程数、最大排队数及已结束任务的保留时长
+	JobFile      string
+	JobWorkers   int
+	JobQueueSize int
+	JobRetention time.Duration
+	// 批量润色同时调用AI服务的数量及单次最多作文数
+	BatchConcurrency int
+	BatchMaxItems    int
+	// 作文存储: auto、dynamodb 或 file，以及 file 存储使用的目录
+	EssayStore string
+	EssayDir   string
 	// AWS DynamoDB 配置
 	AWSRegion      string
 	DynamoDBTable  string
@@ -28,6 +74,43 @@ func LoadConfig() *Config {
 		AIKey:          getEnv("AI_KEY", ""),
 		DeepSeekModel:  getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
 		DeepSeekAPIKey: getEnv("DEEPSEEK_API_KEY", "sk-e75601b8d3224e30aca1acf0b27964f8"),
+		// OpenAI 兼容服务配置
+		OpenAIEndpoint: getEnv("OPENAI_ENDPOINT", ""),
+		OpenAIAPIKey:   getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel:    getEnv("OPENAI_MODEL", ""),
+		// AI服务提供方、重试与超时配置
+		AIProvider:         getEnv("AI_PROVIDER", "auto"),
+		AIProviderCooldown: getDurationEnv("AI_PROVIDER_COOLDOWN", time.Minute),
+		AIRequestTimeout:   getDurationEnv("AI_REQUEST_TIMEOUT", 55*time.Second),
+		AIRetryMaxAttempts: getIntEnv("AI_RETRY_MAX_ATTEMPTS", 3),
+		AIRetryBaseDelay:   getDurationEnv("AI_RETRY_BASE_DELAY", 500*time.Millisecond),
+		AIRetryMaxDelay:    getDurationEnv("AI_RETRY_MAX_DELAY", 10*time.Second),
+		AIChunkSize:        getIntEnv("AI_CHUNK_SIZE", 1500),
+		AIChunkContext:     getIntEnv("AI_CHUNK_CONTEXT", 200),
+		AIChunkTimeout:     getDurationEnv("AI_CHUNK_TIMEOUT", 5*time.Minute),
+		AICacheSize:        getIntEnv("AI_CACHE_SIZE", 500),
+		AICacheTTL:         getDurationEnv("AI_CACHE_TTL", time.Hour),
+		// 提示词模板配置
+		PromptDir:            getEnv("PROMPT_DIR", "prompts"),
+		PromptReloadInterval: getDurationEnv("PROMPT_RELOAD_INTERVAL", 5*time.Second),
+		RubricFile:           getEnv("RUBRIC_FILE", "prompts/rubric.json"),
+		// 用量统计配置
+		UsageFile:     getEnv("USAGE_FILE", "data/usage.json"),
+		AITokenPrices: getEnv("AI_TOKEN_PRICES", "deepseek-chat=2/8,deepseek-reasoner=4/16"),
+		// 配额配置
+		QuotaFile:      getEnv("QUOTA_FILE", "data/quota.json"),
+		QuotaStateFile: getEnv("QUOTA_STATE_FILE", "data/quota_state.json"),
+		// 异步润色任务配置
+		JobFile:      getEnv("JOB_FILE", "data/jobs.json"),
+		JobWorkers:   getIntEnv("JOB_WORKERS", 2),
+		JobQueueSize: getIntEnv("JOB_QUEUE_SIZE", 100),
+		JobRetention: getDurationEnv("JOB_RETENTION", 24*time.Hour),
+		// 批量润色配置
+		BatchConcurrency: getIntEnv("BATCH_CONCURRENCY", 3),
+		BatchMaxItems:    getIntEnv("BATCH_MAX_ITEMS", 50),
+		// 作文存储配置
+		EssayStore: getEnv("ESSAY_STORE", "auto"),
+		EssayDir:   getEnv("ESSAY_DIR", "data/essays"),
 		// AWS DynamoDB 配置
 		AWSRegion:      getEnv("AWS_REGION", "ap-northeast-1"),
 		DynamoDBTable:  getEnv("DYNAMODB_TABLE", "essay"),
@@ -50,3 +133,31 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getDurationEnv 获取时长类型的环境变量（如 "30s"、"5m"），格式无效时返回默认值
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("警告: 环境变量 %s 的值 %q 不是有效的时长，使用默认值 %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+// getIntEnv 获取整数类型的环境变量，格式无效时返回默认值
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("警告: 环境变量 %s 的值 %q 不是有效的整数，使用默认值 %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -27,7 +29,18 @@ type LoginResponse struct {
 
 // SyncEssaysRequest 同步请求结构
 type SyncEssaysRequest struct {
-	Essays []models.Essay `json:"essays" binding:"required"`
+	Since   int64          `json:"since"`   // 上次同步返回的 cursor，0 表示首次同步
+	Changes []models.Essay `json:"changes"` // 本设备新建或修改过的作文
+	// Essays 旧版本页面上传的作文，与 Changes 一起保存
+	Essays []models.Essay `json:"essays"`
+}
+
+// SyncEssaysResponse 同步响应结构
+type SyncEssaysResponse struct {
+	Cursor    int64           `json:"cursor"`    // 下次同步时作为 since 提交
+	Saved     []*models.Essay `json:"saved"`     // 与请求中的作文一一对应，冲突的为 null
+	Changes   []models.Essay  `json:"changes"`   // 自 since 以来其他设备的修改，已删除的作文只有删除标记
+	Conflicts []*models.Essay `json:"conflicts"` // 已在其他设备上修改而未保存的作文在服务器上的版本
 }
 
 // Login 处理用户登录
@@ -86,7 +99,10 @@ func GetUserInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// SyncEssays 同步作文到DynamoDB
+// SyncEssays 增量同步作文
+//
+// 客户端提交上次同步的游标和本设备修改过的作文，服务器保存后返回自游标以来其他设备的修改
+// （包括删除）、版本冲突的作文和新的游标，同步的数据量只取决于修改的作文数量。
 func SyncEssays(c *gin.Context) {
 	// 从上下文中获取用户名
 	username, exists := c.Get("username")
@@ -96,35 +112,86 @@ func SyncEssays(c *gin.Context) {
 	}
 
 	var req SyncEssaysRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil || req.Since < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
 
-	// 获取DynamoDB客户端
-	dynamoDBClient := services.GetDynamoDBClient()
-	if dynamoDBClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DynamoDB服务未初始化"})
+	// 获取作文存储
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
 		return
 	}
 
-	// 保存每篇作文
-	for _, essay := range req.Essays {
+	// 保存每篇作文，已在其他设备上修改的作文不覆盖，返回服务器上的版本由客户端处理
+	uploaded := append(req.Changes, req.Essays...)
+	resp := SyncEssaysResponse{
+		Saved:     make([]*models.Essay, len(uploaded)),
+		Changes:   []models.Essay{},
+		Conflicts: []*models.Essay{},
+	}
+	// 本次保存和冲突的作文已经在 saved 和 conflicts 中返回，不再出现在 changes 中
+	returned := make(map[int64]int64)
+	for i := range uploaded {
+		essay := uploaded[i]
 		// 确保作文属于当前用户
 		essay.Username = username.(string)
-		
-		// 保存到DynamoDB
-		err := dynamoDBClient.SaveEssay(essay)
+
+		// 保存作文，服务器上已经没有的作文（例如存储被重置过）作为新作文保存，不丢失本设备的内容
+		err := essayStore.SaveEssay(&essay)
+		if errors.Is(err, services.ErrEssayNotFound) {
+			essay.ID, essay.Version = 0, 0
+			err = essayStore.SaveEssay(&essay)
+		}
+		var conflict *services.VersionConflictError
+		if errors.As(err, &conflict) {
+			resp.Conflicts = append(resp.Conflicts, conflict.Current)
+			returned[conflict.Current.ID] = conflict.Current.Version
+			continue
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存作文失败"})
 			return
 		}
+		resp.Saved[i] = &essay
+		returned[essay.ID] = essay.Version
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "同步成功"})
+	changes, cursor, err := essayStore.GetEssayChanges(username.(string), req.Since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取作文失败"})
+		return
+	}
+	resp.Cursor = cursor
+	for _, essay := range changes {
+		if version, ok := returned[essay.ID]; ok && version == essay.Version {
+			continue
+		}
+		resp.Changes = append(resp.Changes, tombstone(essay))
+	}
+
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[SyncEssays] 用户: %s, 游标: %d -> %d, 上传 %d 篇, 冲突 %d 篇, 下发 %d 篇\n",
+		username, req.Since, resp.Cursor, len(uploaded), len(resp.Conflicts), len(resp.Changes))))
+	c.JSON(http.StatusOK, resp)
+}
+
+// tombstone 已删除的作文只返回客户端删除本地副本所需的字段，未删除的作文原样返回
+func tombstone(essay models.Essay) models.Essay {
+	if essay.DeletedAt == "" {
+		return essay
+	}
+	return models.Essay{
+		Username:  essay.Username,
+		ID:        essay.ID,
+		UpdatedAt: essay.UpdatedAt,
+		DeletedAt: essay.DeletedAt,
+		Version:   essay.Version,
+		Seq:       essay.Seq,
+	}
 }
 
-// GetEssays 从DynamoDB获取用户的所有作文
+// GetEssays 获取用户的所有作文
 func GetEssays(c *gin.Context) {
 	// 从上下文中获取用户名
 	username, exists := c.Get("username")
@@ -133,15 +200,15 @@ func GetEssays(c *gin.Context) {
 		return
 	}
 
-	// 获取DynamoDB客户端
-	dynamoDBClient := services.GetDynamoDBClient()
-	if dynamoDBClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DynamoDB服务未初始化"})
+	// 获取作文存储
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
 		return
 	}
 
 	// 获取用户的所有作文
-	essays, err := dynamoDBClient.GetEssaysByUsername(username.(string))
+	essays, err := essayStore.GetEssaysByUsername(username.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取作文失败"})
 		return
@@ -150,7 +217,7 @@ func GetEssays(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"essays": essays})
 }
 
-// DeleteEssay 从DynamoDB软删除作文
+// DeleteEssay 软删除作文
 func DeleteEssay(c *gin.Context) {
 	// 从上下文中获取用户名
 	username, exists := c.Get("username")
@@ -174,17 +241,31 @@ func DeleteEssay(c *gin.Context) {
 		return
 	}
 
-	// 获取DynamoDB客户端
-	dynamoDBClient := services.GetDynamoDBClient()
-	if dynamoDBClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DynamoDB服务未初始化"})
+	// 获取作文存储
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
 		return
 	}
 
-	// 软删除作文，使用 username 和 id 作为主键和排序键
-	err = dynamoDBClient.DeleteEssay(username.(string), essayID)
+	// 客户端读到的版本号，不提供时删除当前版本
+	var version int64
+	if v := c.Query("version"); v != "" {
+		version, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+			return
+		}
+	}
+
+	// 软删除作文
+	err = essayStore.DeleteEssay(username.(string), essayID, version)
+	if errors.Is(err, services.ErrEssayNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "作文不存在"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除作文失败: " + err.Error()})
+		writeSaveError(c, err, "删除作文失败: "+err.Error())
 		return
 	}
 
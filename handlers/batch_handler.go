@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// batchConcurrency 和 batchMaxItems 由 main 根据配置设置
+var (
+	batchConcurrency = 3
+	batchMaxItems    = 50
+)
+
+// sseWriteWait 写入每个SSE事件的超时时间；批量润色和流式润色的总时长可能超过服务器的 WriteTimeout，
+// 每次写入前重新设置写入截止时间
+const sseWriteWait = 10 * time.Second
+
+// SetBatchLimits 设置批量润色的并发数和单次最多作文数
+func SetBatchLimits(concurrency, maxItems int) {
+	if concurrency > 0 {
+		batchConcurrency = concurrency
+	}
+	if maxItems > 0 {
+		batchMaxItems = maxItems
+	}
+}
+
+// PolishEssayBatch 批量润色作文，并把每篇结果保存为当前用户的作文
+//
+// 以受控的并发数调用AI服务，通过SSE逐篇推送进度：每完成一篇发送一个 item 事件，
+// 全部完成后发送 done 事件。每篇作文单独计入润色配额。客户端断开或推送失败后，
+// 尚未完成的作文不再润色和保存。
+func PolishEssayBatch(c *gin.Context) {
+	// 从上下文中获取用户名
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	var request models.BatchPolishRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if len(request.Items) == 0 || len(request.Items) > batchMaxItems {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": fmt.Sprintf("每次可以润色 1 到 %d 篇作文", batchMaxItems),
+		})
+		return
+	}
+
+	// 先校验所有作文，避免处理到一半才发现参数错误
+	polishRequests := make([]*services.PolishRequest, len(request.Items))
+	for i, item := range request.Items {
+		if strings.TrimSpace(item.Content) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": fmt.Sprintf("第 %d 篇作文内容为空", i+1),
+			})
+			return
+		}
+		polishRequests[i] = services.NewPolishRequest(item)
+		polishRequests[i].Structured = true
+		if err := preparePolishRequest(c, polishRequests[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": fmt.Sprintf("第 %d 篇作文: %v", i+1, err),
+			})
+			return
+		}
+	}
+
+	// 获取作文存储
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
+		return
+	}
+
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayBatch] 开始批量润色, 用户: %s, 共 %d 篇, 并发数: %d\n", username, len(polishRequests), batchConcurrency)))
+
+	// 设置SSE相关的响应头
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	// 推送失败时取消剩余的作文，客户端已经收不到结果
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	responseController := http.NewResponseController(c.Writer)
+	send := func(event string, data any) {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := writeSSEEvent(c, responseController, event, data); err != nil {
+			gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayBatch] 推送进度失败，停止润色剩余作文: %v\n", err)))
+			cancel()
+		}
+	}
+	send("start", gin.H{"total": len(polishRequests)})
+
+	// 用信号量限制同时调用AI服务的数量，结果交给当前协程统一写入响应
+	role := services.GetAuthService().RoleOf(username.(string))
+	results := make(chan models.BatchItemResult)
+	semaphore := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, polishRequest := range polishRequests {
+		wg.Add(1)
+		go func(index int, polishRequest *services.PolishRequest) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results <- models.BatchItemResult{Index: index, Title: polishRequest.Title, Status: "failed", Error: "请求已取消"}
+				return
+			}
+			results <- polishBatchItem(ctx, essayStore, role, index, polishRequest)
+		}(i, polishRequest)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	succeeded := 0
+	for result := range results {
+		if result.Status == "succeeded" {
+			succeeded++
+		}
+		send("item", result)
+	}
+
+	send("done", gin.H{
+		"total":     len(polishRequests),
+		"succeeded": succeeded,
+		"failed":    len(polishRequests) - succeeded,
+	})
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayBatch] 批量润色完成, 成功 %d 篇, 失败 %d 篇\n", succeeded, len(polishRequests)-succeeded)))
+}
+
+// writeSSEEvent 延长写入截止时间后推送一个SSE事件
+func writeSSEEvent(c *gin.Context, responseController *http.ResponseController, event string, data any) error {
+	err := responseController.SetWriteDeadline(time.Now().Add(sseWriteWait))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if err := sse.Encode(c.Writer, sse.Event{Event: event, Data: data}); err != nil {
+		return err
+	}
+	return responseController.Flush()
+}
+
+// polishBatchItem 润色一篇作文并保存，在工作协程中调用，不能写入响应
+func polishBatchItem(ctx context.Context, essayStore services.EssayStore, role string, index int, polishRequest *services.PolishRequest) models.BatchItemResult {
+	item := models.BatchItemResult{Index: index, Title: polishRequest.Title, Status: "failed"}
+
+	if quotaService := services.GetQuotaService(); quotaService != nil {
+		if allowed, _ := quotaService.Acquire(polishRequest.Username, role); !allowed {
+			item.Error = "润色配额已用完"
+			return item
+		}
+	}
+
+	result, err := services.GetAIService().PolishEssay(ctx, polishRequest)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	item.Provider = result.Provider
+	item.Usage = result.Usage
+	item.Cached = result.Cached
+
+	// 客户端已经断开时不保存，结果不会被看到
+	if err := ctx.Err(); err != nil {
+		item.Error = "请求已取消"
+		return item
+	}
+
+	essay := &models.Essay{
+		Username:        polishRequest.Username,
+		Title:           polishRequest.Title,
+		OriginalContent: polishRequest.Content,
+		PolishedContent: result.Content,
+	}
+	if err := essayStore.SaveEssay(essay); err != nil {
+		item.Error = "保存作文失败: " + err.Error()
+		return item
+	}
+
+	item.EssayID = essay.ID
+	item.Status = "succeeded"
+	return item
+}
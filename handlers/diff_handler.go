@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// GetEssayDiff 返回作文修改前后的差异
+//
+// 默认对比作文的原文和润色后内容；指定 against=<id> 时，以另一篇作文为基准对比两个版本。
+func GetEssayDiff(c *gin.Context) {
+	// 从上下文中获取用户名
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	essayID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作文ID格式"})
+		return
+	}
+
+	var againstID int64
+	if against := c.Query("against"); against != "" {
+		againstID, err = strconv.ParseInt(against, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对比作文ID格式"})
+			return
+		}
+	}
+
+	// 获取作文存储
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
+		return
+	}
+
+	essay, ok := loadEssay(c, essayStore, username.(string), essayID)
+	if !ok {
+		return
+	}
+
+	base, ok := loadAgainst(c, essayStore, essay.Username, againstID)
+	if !ok {
+		return
+	}
+	original, revised := diffSides(essay, base)
+
+	// 合并时需要提交这里的版本号，确认修改序号对应的仍是同样的内容
+	response := gin.H{
+		"essayId":  essayID,
+		"version":  essay.Version,
+		"against":  againstID,
+		"original": original,
+		"revised":  revised,
+		"changes":  services.DiffText(original, revised),
+	}
+	if base != nil {
+		response["againstVersion"] = base.Version
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// MergeEssay 根据用户接受的修改生成合并后的作文，保存为以该作文为父版本的新作文
+//
+// 修改序号与 GET /api/essays/:id/diff 返回的 id 一致，服务端会重新计算差异，未被接受的修改保留原文。
+func MergeEssay(c *gin.Context) {
+	// 从上下文中获取用户名
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	essayID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作文ID格式"})
+		return
+	}
+
+	var req models.MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Against != 0 && req.AgainstVersion == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数，需要提供获取差异时的版本号"})
+		return
+	}
+
+	// 获取作文存储
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
+		return
+	}
+
+	essay, ok := loadEssay(c, essayStore, username.(string), essayID)
+	if !ok {
+		return
+	}
+	base, ok := loadAgainst(c, essayStore, essay.Username, req.Against)
+	if !ok {
+		return
+	}
+
+	// 获取差异之后作文被修改过时，修改序号对应的已经是其他修改，不能合并
+	if essay.Version != req.Version {
+		writeSaveError(c, &services.VersionConflictError{Current: essay}, "")
+		return
+	}
+	if base != nil && base.Version != req.AgainstVersion {
+		writeSaveError(c, &services.VersionConflictError{Current: base}, "")
+		return
+	}
+
+	original, revised := diffSides(essay, base)
+	changes := services.DiffText(original, revised)
+	accepted := make(map[int]bool, len(req.Accepted))
+	for _, id := range req.Accepted {
+		if id < 1 || id > len(changes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("修改序号 %d 不存在", id)})
+			return
+		}
+		accepted[id] = true
+	}
+
+	merged := &models.Essay{
+		Username:        username.(string),
+		Title:           essay.Title,
+		OriginalContent: original,
+		PolishedContent: services.ApplyTextChanges(original, changes, accepted),
+		ParentID:        essay.ID,
+	}
+	if err := essayStore.SaveEssay(merged); err != nil {
+		writeSaveError(c, err, "保存作文失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"essay":    merged,
+		"accepted": len(accepted),
+		"rejected": len(changes) - len(accepted),
+	})
+}
+
+// loadAgainst 获取对比的基准作文，againstID 为 0 时返回 nil，失败时写入错误响应并返回 false
+func loadAgainst(c *gin.Context, essayStore services.EssayStore, username string, againstID int64) (*models.Essay, bool) {
+	if againstID == 0 {
+		return nil, true
+	}
+	return loadEssay(c, essayStore, username, againstID)
+}
+
+// diffSides 返回对比的两段文本：base 为 nil 时是作文的原文和润色后内容，否则是基准作文和该作文的正文
+func diffSides(essay, base *models.Essay) (string, string) {
+	if base == nil {
+		return essay.OriginalContent, essay.PolishedContent
+	}
+	return essayText(base), essayText(essay)
+}
+
+// loadEssay 获取当前用户未删除的作文，失败时写入错误响应并返回 false
+func loadEssay(c *gin.Context, essayStore services.EssayStore, username string, essayID int64) (*models.Essay, bool) {
+	essay, err := essayStore.GetEssay(username, essayID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取作文失败"})
+		return nil, false
+	}
+	if essay == nil || essay.DeletedAt != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "作文不存在"})
+		return nil, false
+	}
+	return essay, true
+}
+
+// writeSaveError 写入保存作文失败的响应，版本冲突时返回 409 和服务器上的最新版本
+func writeSaveError(c *gin.Context, err error, message string) {
+	var conflict *services.VersionConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "作文已在其他设备上修改", "essay": conflict.Current})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// essayText 返回作文当前版本的正文，有润色结果时使用润色结果
+func essayText(essay *models.Essay) string {
+	if essay.PolishedContent != "" {
+		return essay.PolishedContent
+	}
+	return essay.OriginalContent
+}
@@ -1,34 +1,61 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
+	"strconv"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 
-	"essay-go/config"
 	"essay-go/models"
 	"essay-go/services"
 )
 
-
-
-// PolishEssayStream 处理作文润色流式输出请求
+// PolishEssayStream 处理作文润色流式输出请求，请求体与 /api/polish 相同，以SSE格式返回润色结果
 func PolishEssayStream(c *gin.Context) {
-	// 记录请求开始
 	gin.DefaultWriter.Write([]byte("[PolishEssayStream] 开始处理流式润色请求\n"))
-	
-	// 获取请求参数
-	title := c.Query("title")
-	content := c.Query("content")
-	
+
+	var request models.EssayRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 请求参数绑定失败: %v\n", err)))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	streamPolish(c, request)
+}
+
+// PolishEssayStreamGet 从查询参数读取作文的流式润色请求
+//
+// Deprecated: 长作文会超出URL长度限制，作文正文也会出现在访问日志中，请使用 POST /api/polish/stream。
+func PolishEssayStreamGet(c *gin.Context) {
+	gin.DefaultWriter.Write([]byte("[PolishEssayStreamGet] 收到已弃用的 GET 流式润色请求\n"))
+	c.Header("Deprecation", "true")
+	c.Header("Link", `</api/polish/stream>; rel="successor-version"`)
+
+	wordLimit, _ := strconv.Atoi(c.Query("wordLimit"))
+	gradeLevel, _ := strconv.Atoi(c.Query("gradeLevel"))
+	streamPolish(c, models.EssayRequest{
+		Title:      c.Query("title"),
+		Content:    c.Query("content"),
+		Mode:       c.Query("mode"),
+		WordLimit:  wordLimit,
+		GradeLevel: gradeLevel,
+	})
+}
+
+// streamPolish 校验润色参数并以SSE格式逐段返回润色结果
+func streamPolish(c *gin.Context, request models.EssayRequest) {
 	// 记录请求内容
-	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 收到请求: 标题=%s, 内容长度=%d\n", title, len(content))))
-	
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 收到请求: 标题=%s, 模式=%s, 内容长度=%d\n", request.Title, request.Mode, len(request.Content))))
+
 	// 验证内容不为空
-	if content == "" {
+	if request.Content == "" {
 		gin.DefaultWriter.Write([]byte("[PolishEssayStream] 作文内容为空\n"))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -36,71 +63,94 @@ func PolishEssayStream(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	polishRequest := services.NewPolishRequest(request)
+	if err := preparePolishRequest(c, polishRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// 设置SSE相关的响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Status(http.StatusOK)
-	
+
+	// 每次写入前延长写入截止时间，长作文的润色时间可能超过服务器的 WriteTimeout
+	responseController := http.NewResponseController(c.Writer)
+
 	// 立即发送一个初始消息，确保连接建立
-	c.SSEvent("", "正在润色中...")
-	
-	// 创建AI服务
-	gin.DefaultWriter.Write([]byte("[PolishEssayStream] 创建AI服务\n"))
-	aiService := services.NewAIService(config.LoadConfig())
-	
-	// 调用AI服务润色作文
-	gin.DefaultWriter.Write([]byte("[PolishEssayStream] 调用AI服务润色作文\n"))
-	polishedContent, err := aiService.PolishEssay(title, content)
+	if err := writeSSEEvent(c, responseController, "", "正在润色中..."); err != nil {
+		gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 写入响应失败: %v\n", err)))
+		return
+	}
+
+	aiService := services.GetAIService()
+
+	// 调用AI服务流式润色作文，收到增量内容后立即转发给浏览器
+	gin.DefaultWriter.Write([]byte("[PolishEssayStream] 调用AI服务流式润色作文\n"))
+	chunkCount := 0
+	result, err := aiService.PolishEssayStream(c.Request.Context(), polishRequest, func(delta string) error {
+		// 客户端已断开或写入超时时停止转发
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		if err := writeSSEEvent(c, responseController, "", delta); err != nil {
+			return err
+		}
+		chunkCount++
+		return nil
+	})
 	if err != nil {
 		errMsg := fmt.Sprintf("[PolishEssayStream] 润色作文失败: %v\n", err)
 		gin.DefaultWriter.Write([]byte(errMsg))
-		
+
 		// 发送错误事件
-		c.SSEvent("error", fmt.Sprintf("润色失败: %v", err))
+		writeSSEEvent(c, responseController, "error", fmt.Sprintf("润色失败: %v", err))
 		return
 	}
-	
-	// 将润色结果分成多个小块流式输出
-	chunks := splitIntoChunks(polishedContent, 10) // 每10个字符一个块
-	
-	for i, chunk := range chunks {
-		// 模拟处理时间
-		time.Sleep(100 * time.Millisecond)
-		
-		// 使用Gin的SSEvent函数发送数据
-		c.SSEvent("", chunk)
-		
-		gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 发送块 %d: %s\n", i, chunk)))
-		
-		// 手动刷新缓冲区
-		c.Writer.Flush()
+
+	// 告知客户端修改之处和实际完成润色的提供方，然后发送完成标记
+	events := []sse.Event{{Event: "changes", Data: services.DiffText(request.Content, result.Content)}}
+	if result.Cached {
+		events = append(events, sse.Event{Event: "cached", Data: "true"})
+	}
+	events = append(events,
+		sse.Event{Event: "provider", Data: result.Provider},
+		sse.Event{Data: "[DONE]"},
+	)
+	for _, event := range events {
+		if err := writeSSEEvent(c, responseController, event.Event, event.Data); err != nil {
+			gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 写入响应失败: %v\n", err)))
+			return
+		}
 	}
-	
-	// 发送完成标记
-	time.Sleep(100 * time.Millisecond)
-	c.SSEvent("", "[DONE]")
-	c.Writer.Flush()
-	
-	gin.DefaultWriter.Write([]byte("[PolishEssayStream] 处理完成\n"))
+
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssayStream] 处理完成, 提供方: %s, 共发送 %d 块, 润色后内容长度: %d\n", result.Provider, chunkCount, len(result.Content))))
 }
 
-// splitIntoChunks 将文本按 rune (字符) 分成多个小块
-func splitIntoChunks(text string, chunkSize int) []string {
-	var chunks []string
-	runes := []rune(text) // 将字符串转换为 rune 切片
-	textLenInRunes := len(runes)
+// preparePolishRequest 校验润色参数，记录发起请求的登录用户，未指定年级时使用该用户资料中的年级
+func preparePolishRequest(c *gin.Context, req *services.PolishRequest) error {
+	if !models.IsValidPolishMode(req.Mode) {
+		return fmt.Errorf("不支持的润色模式: %s", req.Mode)
+	}
 
-	for i := 0; i < textLenInRunes; i += chunkSize {
-		end := i + chunkSize
-		if end > textLenInRunes {
-			end = textLenInRunes
+	if username, exists := c.Get("username"); exists {
+		req.Username = username.(string)
+		if req.GradeLevel == 0 {
+			if user := services.GetAuthService().GetUser(req.Username); user != nil {
+				req.GradeLevel = user.GradeLevel
+			}
 		}
-		chunks = append(chunks, string(runes[i:end])) // 将 rune 子切片转换回字符串
 	}
-	return chunks
+	if req.GradeLevel != 0 && !models.IsValidGradeLevel(req.GradeLevel) {
+		return fmt.Errorf("年级必须在 1 到 12 之间")
+	}
+	return nil
 }
 
 // PolishEssay 处理作文润色请求
@@ -120,8 +170,8 @@ func PolishEssay(c *gin.Context) {
 		return
 	}
 
-	reqJSON, _ := json.Marshal(request) // Safe to ignore error for logging
-	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssay] 收到请求: %s\n", string(reqJSON))))
+	// 只记录元数据，不记录作文正文
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssay] 收到请求: 标题=%s, 模式=%s, 内容长度=%d\n", request.Title, request.Mode, len(request.Content))))
 
 	if request.Content == "" {
 		gin.DefaultWriter.Write([]byte("[PolishEssay] 作文内容为空\n"))
@@ -131,13 +181,21 @@ func PolishEssay(c *gin.Context) {
 		})
 		return
 	}
-	
-	gin.DefaultWriter.Write([]byte("[PolishEssay] 创建AI服务\n"))
-	aiService := services.NewAIService(config.LoadConfig())
+
+	polishRequest := services.NewPolishRequest(request)
+	polishRequest.Structured = true
+	if err := preparePolishRequest(c, polishRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	aiService := services.GetAIService()
 
 	gin.DefaultWriter.Write([]byte("[PolishEssay] 调用AI服务润色作文\n"))
-	// 修改：接收 polishedContent
-	polishedContent, err := aiService.PolishEssay(request.Title, request.Content) 
+	result, err := aiService.PolishEssay(c.Request.Context(), polishRequest)
 	if err != nil {
 		errMsg := fmt.Sprintf("[PolishEssay] AI服务润色作文失败: %v\n", err)
 		gin.DefaultWriter.Write([]byte(errMsg))
@@ -148,27 +206,26 @@ func PolishEssay(c *gin.Context) {
 		})
 		return
 	}
-	
-	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssay] AI服务调用完成, 润色后内容长度: %d\n", len(polishedContent))))
-
-	// ---- 修改：返回实际的润色结果 ----
-	actualResponse := gin.H{
-		"title":           request.Title, // 或者您可以考虑让 AI 服务也返回处理后的标题
-		"polishedContent": polishedContent,
-		"status":          "success", // 或者 "ok"
+
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssay] AI服务调用完成, 提供方: %s, 润色后内容长度: %d\n", result.Provider, len(result.Content))))
+
+	response := models.EssayResponse{
+		Title:           request.Title,
+		PolishedContent: result.Content,
+		Mode:            polishRequest.Mode,
+		GradeLevel:      polishRequest.GradeLevel,
+		Provider:        result.Provider,
+		Feedback:        result.Feedback,
+		Changes:         services.DiffText(request.Content, result.Content),
+		Usage:           result.Usage,
+		Cached:          result.Cached,
+		Status:          "success",
 	}
-	
-	// 可选：记录实际发送的响应
-	actualRespJSON, _ := json.Marshal(actualResponse)
-	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssay] PREPARING ACTUAL RESPONSE: %s\n", string(actualRespJSON))))
-	
+
 	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
 	c.Header("Pragma", "no-cache")
 	c.Header("Expires", "0")
+	c.JSON(http.StatusOK, response)
 
-	gin.DefaultWriter.Write([]byte("[PolishEssay] SENDING ACTUAL JSON RESPONSE\n"))
-	c.JSON(http.StatusOK, actualResponse) 
-	// ---- 结束修改 ----
-	
-	gin.DefaultWriter.Write([]byte("[PolishEssay] 处理完成 (after sending actual response)\n"))
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishEssay] 处理完成, 修改 %d 处, token用量: %d\n", len(response.Changes), response.Usage.TotalTokens)))
 }
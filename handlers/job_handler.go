@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// SubmitPolishJob 提交异步润色任务，立即返回任务ID，之后通过 GET /api/polish/jobs/:id 查询状态和结果
+func SubmitPolishJob(c *gin.Context) {
+	var request models.EssayRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	polishRequest := services.NewPolishRequest(request)
+	if err := preparePolishRequest(c, polishRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	jobQueue := services.GetJobQueue()
+	if jobQueue == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "润色任务队列未初始化"})
+		return
+	}
+
+	job, err := jobQueue.Submit(polishRequest)
+	if errors.Is(err, services.ErrJobQueueFull) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交润色任务失败"})
+		return
+	}
+
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[SubmitPolishJob] 任务已提交: %s\n", job.ID)))
+	c.JSON(http.StatusAccepted, gin.H{
+		"jobId":  job.ID,
+		"status": job.Status,
+	})
+}
+
+// GetPolishJob 查询异步润色任务的状态，完成后包含润色结果
+func GetPolishJob(c *gin.Context) {
+	job, ok := loadPolishJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelPolishJob 取消排队中或正在处理的润色任务
+func CancelPolishJob(c *gin.Context) {
+	if _, ok := loadPolishJob(c); !ok {
+		return
+	}
+
+	job, err := services.GetJobQueue().Cancel(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// loadPolishJob 获取当前用户有权访问的任务，失败时写入错误响应并返回 false
+//
+// 登录用户提交的任务只有本人可以访问，未登录时提交的任务凭任务ID访问。
+func loadPolishJob(c *gin.Context) (*models.PolishJob, bool) {
+	jobQueue := services.GetJobQueue()
+	if jobQueue == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "润色任务队列未初始化"})
+		return nil, false
+	}
+
+	job, err := jobQueue.Get(c.Param("id"))
+	if err != nil || (job.Username != "" && job.Username != c.GetString("username")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return nil, false
+	}
+	return job, true
+}
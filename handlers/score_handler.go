@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// ScoreEssay 按评分标准给作文打分
+//
+// 登录用户在请求中指定 essayId 时，按该作文当前的正文评分（忽略请求中的 content），
+// 评分结果会保存到该作文上，用于查看分数趋势。
+func ScoreEssay(c *gin.Context) {
+	var request models.ScoreRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	username, loggedIn := c.Get("username")
+	if request.EssayID != 0 && !loggedIn {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "保存评分结果需要登录"})
+		return
+	}
+
+	// 指定了已保存的作文时，在调用AI之前确认作文存在，并按作文当前的正文评分
+	var essay *models.Essay
+	var essayStore services.EssayStore
+	if request.EssayID != 0 {
+		essayStore = services.GetEssayStore()
+		if essayStore == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
+			return
+		}
+
+		var ok bool
+		essay, ok = loadEssay(c, essayStore, username.(string), request.EssayID)
+		if !ok {
+			return
+		}
+		request.Content = essayText(essay)
+		if request.Title == "" {
+			request.Title = essay.Title
+		}
+	}
+
+	if strings.TrimSpace(request.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "作文内容不能为空",
+		})
+		return
+	}
+
+	polishRequest := services.NewPolishRequest(models.EssayRequest{
+		Title:      request.Title,
+		Content:    request.Content,
+		GradeLevel: request.GradeLevel,
+	})
+	if err := preparePolishRequest(c, polishRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[ScoreEssay] 开始评分, 标题: %s, 年级: %d\n", request.Title, polishRequest.GradeLevel)))
+	score, err := services.GetAIService().ScoreEssay(c.Request.Context(), polishRequest, services.GetRubric())
+	if err != nil {
+		gin.DefaultWriter.Write([]byte(fmt.Sprintf("[ScoreEssay] 评分失败: %v\n", err)))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "作文评分失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 把评分结果记录到已保存的作文上，评分期间作文被修改时返回 409
+	if essay != nil {
+		essay.Score = score
+		if err := essayStore.SaveEssay(essay); err != nil {
+			writeSaveError(c, err, "保存评分结果失败")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"score":  score,
+		"status": "success",
+	})
+}
+
+// GetScoreTrend 返回当前用户已评分作文的分数趋势，按评分时间升序排列
+func GetScoreTrend(c *gin.Context) {
+	// 从上下文中获取用户名
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	essayStore := services.GetEssayStore()
+	if essayStore == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作文存储未初始化"})
+		return
+	}
+
+	essays, err := essayStore.GetEssaysByUsername(username.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取作文失败"})
+		return
+	}
+
+	trend := []models.ScoreTrendPoint{}
+	for _, essay := range essays {
+		if essay.Score == nil {
+			continue
+		}
+		trend = append(trend, models.ScoreTrendPoint{
+			EssayID:  essay.ID,
+			Title:    essay.Title,
+			Total:    essay.Score.Total,
+			MaxTotal: essay.Score.MaxTotal,
+			ScoredAt: essay.Score.ScoredAt,
+		})
+	}
+	sort.Slice(trend, func(i, j int) bool {
+		return trend[i].ScoredAt < trend[j].ScoredAt
+	})
+
+	c.JSON(http.StatusOK, gin.H{"trend": trend})
+}
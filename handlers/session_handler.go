@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// WebSocket 连接的保活参数：定期发送 ping，超过 sessionPongWait 没有收到任何消息就断开
+const (
+	sessionPongWait     = 60 * time.Second
+	sessionPingInterval = 45 * time.Second
+	sessionWriteWait    = 10 * time.Second
+	sessionMaxMessage   = 1 << 20
+)
+
+// sessionUpgrader 使用默认的同源检查，令牌放在查询参数中，不能允许其他站点的页面发起连接
+var sessionUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// polishSessionConn 一个交互式润色连接，AI输出在单独的协程中进行，读取协程可以随时取消
+//
+// 润色协程运行期间会修改 session，读取协程只在没有进行中的润色时访问 session。
+type polishSessionConn struct {
+	conn     *websocket.Conn
+	username string
+	role     string
+	quotaKey string
+	session  *services.PolishSession
+
+	writeMutex sync.Mutex
+
+	mutex      sync.Mutex
+	cancelTurn context.CancelFunc // 正在进行的润色，空闲时为 nil
+	activeTurn int                // 正在进行的润色的序号
+	turns      sync.WaitGroup
+}
+
+// PolishSessionWS 交互式润色：客户端提交作文后以WebSocket流式接收结果，再针对结果继续提出修改要求
+//
+// 服务端保存对话历史，每次修改都把之前的对话一起发给AI服务。每次润色和修改各计一次配额。
+// 浏览器无法为WebSocket设置请求头，登录用户通过 token 查询参数传递令牌。
+func PolishSessionWS(c *gin.Context) {
+	conn, err := sessionUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishSessionWS] WebSocket握手失败: %v\n", err)))
+		return
+	}
+	defer conn.Close()
+
+	username := c.GetString("username")
+	s := &polishSessionConn{
+		conn:     conn,
+		username: username,
+		role:     services.GetAuthService().RoleOf(username),
+		quotaKey: services.QuotaKey(username, c.ClientIP()),
+		session:  services.NewPolishSession(services.GetAIService()),
+	}
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishSessionWS] 会话开始, 用户: %s\n", username)))
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	// 关闭服务器时断开连接，读取循环随之结束
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go s.keepAlive(ctx)
+
+	conn.SetReadLimit(sessionMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+	})
+
+	for {
+		var message models.SessionClientMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) && ctx.Err() == nil {
+				gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishSessionWS] 读取消息失败: %v\n", err)))
+			}
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+		s.handle(ctx, c, &message)
+	}
+
+	// 连接断开后中止正在进行的润色，等它结束后再关闭连接
+	s.cancel()
+	s.turns.Wait()
+	gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishSessionWS] 会话结束, 用户: %s, 共修改 %d 次\n", username, s.session.Turns())))
+}
+
+// handle 处理客户端的一条消息，润色和修改在单独的协程中进行
+func (s *polishSessionConn) handle(ctx context.Context, c *gin.Context, message *models.SessionClientMessage) {
+	if message.Type == models.SessionMessageCancel {
+		s.cancel()
+		return
+	}
+
+	s.mutex.Lock()
+	busy, activeTurn := s.cancelTurn != nil, s.activeTurn
+	s.mutex.Unlock()
+	if busy {
+		s.sendError(activeTurn, http.StatusConflict, "上一次润色尚未完成")
+		return
+	}
+
+	// 此后没有进行中的润色，可以读取和修改 session
+	switch message.Type {
+	case models.SessionMessagePolish, models.SessionMessageResume:
+		if message.Content == "" {
+			s.sendError(0, http.StatusBadRequest, "作文内容不能为空")
+			return
+		}
+		polishRequest := services.NewPolishRequest(message.EssayRequest)
+		if err := preparePolishRequest(c, polishRequest); err != nil {
+			s.sendError(0, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if message.Type == models.SessionMessageResume {
+			if message.PolishedContent == "" {
+				s.sendError(0, http.StatusBadRequest, "润色结果不能为空")
+				return
+			}
+			s.session.Resume(polishRequest, message.PolishedContent)
+			s.send(models.SessionServerMessage{Type: "ready"})
+			return
+		}
+
+		s.startTurn(ctx, 0, message.Content, func(turnCtx context.Context, onDelta func(string) error) (*services.PolishResult, error) {
+			return s.session.Start(turnCtx, polishRequest, onDelta)
+		})
+
+	case models.SessionMessageFollowUp:
+		// 先检查修改要求，无效的追问不计入配额
+		instruction, err := s.session.CheckFollowUp(message.Instruction)
+		if err != nil {
+			s.sendError(s.session.Turns(), http.StatusBadRequest, err.Error())
+			return
+		}
+		s.startTurn(ctx, s.session.Turns()+1, s.session.Polished(), func(turnCtx context.Context, onDelta func(string) error) (*services.PolishResult, error) {
+			return s.session.FollowUp(turnCtx, instruction, onDelta)
+		})
+
+	default:
+		s.sendError(s.session.Turns(), http.StatusBadRequest, fmt.Sprintf("不支持的消息类型: %s", message.Type))
+	}
+}
+
+// startTurn 计入配额后在单独的协程中进行一次润色，previous 是用于对比修改之处的上一版本
+func (s *polishSessionConn) startTurn(ctx context.Context, turn int, previous string, polish func(ctx context.Context, onDelta func(string) error) (*services.PolishResult, error)) {
+	if quotaService := services.GetQuotaService(); quotaService != nil {
+		if allowed, _ := quotaService.Acquire(s.quotaKey, s.role); !allowed {
+			s.sendError(turn, http.StatusTooManyRequests, "润色配额已用完，请在配额重置后再试")
+			return
+		}
+	}
+
+	turnCtx, cancel := context.WithCancel(ctx)
+	s.mutex.Lock()
+	s.cancelTurn = cancel
+	s.activeTurn = turn
+	s.mutex.Unlock()
+
+	s.turns.Add(1)
+	go func() {
+		defer s.turns.Done()
+		defer func() {
+			s.mutex.Lock()
+			s.cancelTurn = nil
+			s.mutex.Unlock()
+			cancel()
+		}()
+
+		s.send(models.SessionServerMessage{Type: "start", Turn: turn})
+		result, err := polish(turnCtx, func(delta string) error {
+			if err := turnCtx.Err(); err != nil {
+				return err
+			}
+			return s.send(models.SessionServerMessage{Type: "delta", Turn: turn, Content: delta})
+		})
+		if err != nil {
+			gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishSessionWS] 第 %d 次润色失败: %v\n", turn, err)))
+			if errors.Is(err, context.Canceled) {
+				// 沿用 nginx 表示客户端取消请求的 499
+				s.sendError(turn, 499, "润色已取消")
+			} else {
+				s.sendError(turn, http.StatusInternalServerError, fmt.Sprintf("润色失败: %v", err))
+			}
+			return
+		}
+
+		usage := result.Usage
+		s.send(models.SessionServerMessage{
+			Type:     "done",
+			Turn:     turn,
+			Content:  result.Content,
+			Provider: result.Provider,
+			Changes:  services.DiffText(previous, result.Content),
+			Usage:    &usage,
+			Cached:   result.Cached,
+		})
+		gin.DefaultWriter.Write([]byte(fmt.Sprintf("[PolishSessionWS] 第 %d 次润色完成, 提供方: %s, 润色后内容长度: %d\n", turn, result.Provider, len(result.Content))))
+	}()
+}
+
+// cancel 中止正在进行的润色
+func (s *polishSessionConn) cancel() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cancelTurn != nil {
+		s.cancelTurn()
+	}
+}
+
+// keepAlive 定期发送 ping，直到连接关闭
+func (s *polishSessionConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(sessionPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.writeMutex.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(sessionWriteWait))
+			s.writeMutex.Unlock()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send 向客户端发送一条消息，读取协程和润色协程都会调用，需要串行写入
+func (s *polishSessionConn) send(message models.SessionServerMessage) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(sessionWriteWait))
+	return s.conn.WriteJSON(message)
+}
+
+// sendError 向客户端发送错误消息
+func (s *polishSessionConn) sendError(turn, code int, message string) {
+	s.send(models.SessionServerMessage{Type: "error", Turn: turn, Code: code, Content: message})
+}
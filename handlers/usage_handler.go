@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// GetUsage 返回token用量统计，按用户、日期、模型分别汇总
+//
+// 同一部署下的用户（家庭或班级）共用一个AI账户，因此登录用户可以查看所有人的用量。
+// 支持的查询参数: username、model、from、to（日期格式为 2006-01-02，包含起止日期）。
+func GetUsage(c *gin.Context) {
+	tracker := services.GetUsageTracker()
+	if tracker == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "用量统计服务未初始化"})
+		return
+	}
+
+	filter := services.UsageFilter{
+		Username: c.Query("username"),
+		Model:    c.Query("model"),
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+	}
+	for _, date := range []string{filter.From, filter.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式应为 YYYY-MM-DD"})
+			return
+		}
+	}
+
+	records := tracker.Query(filter)
+	var total models.UsageSummary
+	for _, record := range records {
+		total.Requests += record.Requests
+		total.Add(record.TokenUsage)
+		total.Cost += record.Cost
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"records": records,
+		"total":   total,
+		"byUser":  services.SummarizeUsage(records, func(record models.UsageRecord) string { return record.Username }),
+		"byDay":   services.SummarizeUsage(records, func(record models.UsageRecord) string { return record.Date }),
+		"byModel": services.SummarizeUsage(records, func(record models.UsageRecord) string { return record.Model }),
+	})
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin" // 保持这个
@@ -18,6 +23,12 @@ func main() {
 	// 加载配置
 	cfg := config.LoadConfig()
 
+	// 管理命令（例如 reset-table），执行后退出，不启动服务器
+	if len(os.Args) > 1 {
+		runCommand(cfg, os.Args[1], os.Args[2:])
+		return
+	}
+
 	// 设置Gin模式
 	if cfg.Production {
 		gin.SetMode(gin.ReleaseMode)
@@ -25,14 +36,49 @@ func main() {
 		gin.SetMode(gin.DebugMode) // 确保在非生产环境下是Debug模式
 	}
 
-	// 初始化 DynamoDB 服务（如果启用）
-	if cfg.EnableDynamoDB {
-		log.Println("初始化 DynamoDB 服务...")
-		services.InitDynamoDB(cfg.AWSRegion, cfg.DynamoDBTable)
+	// 初始化作文存储（DynamoDB 或本地文件）
+	if err := services.InitEssayStore(cfg); err != nil {
+		log.Fatalf("初始化作文存储失败: %v", err)
+	}
+
+	// 加载提示词模板，模板无效时拒绝启动
+	if err := services.InitPromptStore(cfg.PromptDir, cfg.PromptReloadInterval); err != nil {
+		log.Fatalf("加载提示词模板失败: %v", err)
+	}
+
+	// 加载作文评分标准
+	if err := services.InitRubric(cfg.RubricFile); err != nil {
+		log.Fatalf("加载评分标准失败: %v", err)
+	}
+
+	// 加载token用量统计
+	if err := services.InitUsageTracker(cfg.UsageFile, cfg.AITokenPrices); err != nil {
+		log.Fatalf("加载用量统计失败: %v", err)
+	}
+
+	// 加载润色配额
+	if err := services.InitQuotaService(cfg.QuotaFile, cfg.QuotaStateFile); err != nil {
+		log.Fatalf("加载配额配置失败: %v", err)
 	}
 
+	// 初始化 AI 服务（提供方回退链）
+	services.InitAIService(cfg)
+
+	// 所有请求和异步润色任务的上下文都派生自 baseCtx，关闭服务器时取消它，正在进行的 AI 调用随之中止
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	// 启动异步润色任务队列，上次未完成的任务重新排队
+	if err := services.InitJobQueue(baseCtx, cfg.JobFile, cfg.JobWorkers, cfg.JobQueueSize, cfg.JobRetention); err != nil {
+		log.Fatalf("加载润色任务失败: %v", err)
+	}
+
+	// 批量润色的并发数和单次最多作文数
+	handlers.SetBatchLimits(cfg.BatchConcurrency, cfg.BatchMaxItems)
+
 	// 初始化路由
-	router := gin.Default()
+	// 不使用 gin 自带的日志中间件：它会把查询参数原样写入日志，由 middleware.Logger 代替
+	router := gin.New()
 
 	// 加载HTML模板
 	// 确保 'templates' 文件夹在项目的根目录下，并且包含 index.html
@@ -45,9 +91,21 @@ func main() {
 	// API路由
 	api := router.Group("/api")
 	{
-		// 润色相关API
-		api.POST("/polish", handlers.PolishEssay)
-		api.GET("/polish/stream", handlers.PolishEssayStream)
+		// 润色相关API（登录用户使用资料中的年级作为默认值）
+		api.POST("/polish", middleware.OptionalAuth(), middleware.PolishQuota(), handlers.PolishEssay)
+		api.POST("/polish/stream", middleware.OptionalAuth(), middleware.PolishQuota(), handlers.PolishEssayStream)
+		// 已弃用：作文正文放在查询参数中，长作文会超出URL长度限制，请改用 POST
+		api.GET("/polish/stream", middleware.OptionalAuth(), middleware.PolishQuota(), handlers.PolishEssayStreamGet)
+		// 交互式润色：WebSocket 连接，润色后可以继续提出修改要求，每次润色在处理函数中计入配额
+		api.GET("/polish/session", middleware.OptionalAuth(), handlers.PolishSessionWS)
+
+		// 异步润色任务：提交后轮询状态，避免长时间占用连接
+		api.POST("/polish/jobs", middleware.OptionalAuth(), middleware.PolishQuota(), handlers.SubmitPolishJob)
+		api.GET("/polish/jobs/:id", middleware.OptionalAuth(), handlers.GetPolishJob)
+		api.DELETE("/polish/jobs/:id", middleware.OptionalAuth(), handlers.CancelPolishJob)
+
+		// 评分API，登录用户可以把评分记录到已保存的作文上
+		api.POST("/essays/score", middleware.OptionalAuth(), middleware.PolishQuota(), handlers.ScoreEssay)
 
 		// 认证相关API
 		api.POST("/auth/login", handlers.Login)
@@ -57,8 +115,13 @@ func main() {
 		auth.Use(middleware.AuthRequired())
 		{
 			auth.GET("/user", handlers.GetUserInfo)
+			auth.POST("/polish/batch", handlers.PolishEssayBatch)
 			auth.POST("/essays/sync", handlers.SyncEssays)
 			auth.GET("/essays", handlers.GetEssays)
+			auth.GET("/essays/scores", handlers.GetScoreTrend)
+			auth.GET("/usage", handlers.GetUsage)
+			auth.GET("/essays/:id/diff", handlers.GetEssayDiff)
+			auth.POST("/essays/:id/merge", handlers.MergeEssay)
 			auth.DELETE("/essays/:id", handlers.DeleteEssay)
 		}
 	}
@@ -78,10 +141,34 @@ func main() {
 		ReadTimeout:  15 * time.Second, // 稍微增加超时以应对潜在的AI长响应
 		WriteTimeout: 60 * time.Second, // 显著增加写入超时
 		IdleTimeout:  120 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
+	// 收到退出信号后优雅关闭服务器
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-signalCtx.Done()
+
+		log.Println("正在关闭服务器...")
+		cancelRequests()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("关闭服务器失败: %v", err)
+		}
+		// 等待任务队列保存被中断的任务
+		services.GetJobQueue().Wait()
+	}()
+
 	log.Printf("服务器启动在 http://localhost:%s (或 http://%s)", cfg.Port, serverAddr)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("服务器启动失败: %v", err)
 	}
+	<-shutdownDone
+	log.Println("服务器已关闭")
 }
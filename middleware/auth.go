@@ -71,6 +71,10 @@ func OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头获取令牌
 		authHeader := c.GetHeader("Authorization")
+		// 浏览器无法为WebSocket设置请求头，握手请求可以通过 token 查询参数传递令牌
+		if authHeader == "" && c.IsWebsocket() {
+			authHeader = c.Query("token")
+		}
 		if authHeader == "" {
 			// 没有令牌，继续处理请求
 			c.Next()
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"log"
+	"net/url"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,8 +26,8 @@ func Logger() gin.HandlerFunc {
 		// 请求方式
 		reqMethod := c.Request.Method
 
-		// 请求路由
-		reqUri := c.Request.RequestURI
+		// 请求路由，隐去查询参数中的作文正文
+		reqUri := redactQuery(c.Request.URL)
 
 		// 状态码
 		statusCode := c.Writer.Status()
@@ -45,6 +46,25 @@ func Logger() gin.HandlerFunc {
 	}
 }
 
+// redactedQueryParams 记录日志时需要隐去的查询参数
+var redactedQueryParams = []string{"content", "token"}
+
+// redactQuery 返回用于日志的请求路径，隐去作文正文等敏感的查询参数
+func redactQuery(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for _, name := range redactedQueryParams {
+		if query.Has(name) {
+			query.Set(name, "[REDACTED]")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	return u.Path + "?" + query.Encode()
+}
+
 // Recovery 恢复中间件
 func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
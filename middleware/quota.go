@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"essay-go/models"
+	"essay-go/services"
+)
+
+// PolishQuota 在调用AI服务之前检查并计入用户的润色配额，需要放在 OptionalAuth 或 AuthRequired 之后
+//
+// 配额用完时返回 429，响应中包含各项配额的剩余量和重置时间。
+// 处理函数返回 4xx（请求参数无效、作文不存在等）时没有调用AI服务，退还计入的请求。
+func PolishQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quotaService := services.GetQuotaService()
+		if quotaService == nil {
+			c.Next()
+			return
+		}
+
+		username := c.GetString("username")
+		role := services.GetAuthService().RoleOf(username)
+		key := services.QuotaKey(username, c.ClientIP())
+
+		allowed, statuses := quotaService.Acquire(key, role)
+
+		// 通过响应头告知最紧张的一项配额
+		var tightest *models.QuotaStatus
+		for i := range statuses {
+			if tightest == nil || statuses[i].Remaining < tightest.Remaining {
+				tightest = &statuses[i]
+			}
+		}
+		if tightest != nil {
+			c.Header("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+			c.Header("X-RateLimit-Reset", tightest.ResetAt)
+		}
+
+		if !allowed {
+			// 等到最晚重置的那一项已用完的配额重置后才能再次请求
+			var retryAt time.Time
+			for _, status := range statuses {
+				if status.Remaining > 0 {
+					continue
+				}
+				if resetAt, err := time.Parse(time.RFC3339, status.ResetAt); err == nil && resetAt.After(retryAt) {
+					retryAt = resetAt
+				}
+			}
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(retryAt).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code":    429,
+				"message": "润色配额已用完，请在配额重置后再试",
+				"role":    role,
+				"quota":   statuses,
+				"resetAt": retryAt.Format(time.RFC3339),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if status := c.Writer.Status(); status >= 400 && status < 500 {
+			quotaService.Release(key)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+// TextChangeType 修改类型
+type TextChangeType string
+
+const (
+	TextChangeInsert  TextChangeType = "insert"  // 新增内容
+	TextChangeDelete  TextChangeType = "delete"  // 删除内容
+	TextChangeReplace TextChangeType = "replace" // 替换内容
+)
+
+// TextChange 原文与修改后文本之间的一处修改
+//
+// 位置均以字符（rune）为单位，区间左闭右开；插入时 OriginalStart 等于 OriginalEnd。
+type TextChange struct {
+	ID            int            `json:"id"` // 在本次对比结果中的序号，从 1 开始，接受/拒绝修改时使用
+	Type          TextChangeType `json:"type"`
+	Original      string         `json:"original"`
+	Revised       string         `json:"revised"`
+	OriginalStart int            `json:"originalStart"`
+	OriginalEnd   int            `json:"originalEnd"`
+	RevisedStart  int            `json:"revisedStart"`
+	RevisedEnd    int            `json:"revisedEnd"`
+}
+
+// MergeRequest 接受部分修改并生成新版本的请求
+//
+// 修改序号只对获取差异时的内容有效，Version 和 AgainstVersion 必须是差异接口返回的版本号，
+// 作文在此之后被修改过时拒绝合并。
+type MergeRequest struct {
+	Accepted       []int `json:"accepted"`                   // 接受的修改序号，对应差异结果中的 id，其余修改视为拒绝
+	Against        int64 `json:"against,omitempty"`          // 与差异接口的 against 参数一致，为 0 时对比原文和润色后内容
+	Version        int64 `json:"version" binding:"required"` // 获取差异时作文的版本号
+	AgainstVersion int64 `json:"againstVersion,omitempty"`   // 获取差异时基准作文的版本号，指定 against 时必填
+}
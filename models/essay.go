@@ -1,13 +1,79 @@
 package models
 
+// 润色模式
+const (
+	PolishModeProofread = "proofread" // 只修正错别字和标点
+	PolishModePolish    = "polish"    // 全面润色语言表达（默认）
+	PolishModeExpand    = "expand"    // 扩写，补充细节描写
+	PolishModeCondense  = "condense"  // 缩写到指定字数以内
+)
+
 // EssayRequest 作文润色请求结构
 type EssayRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content" binding:"required"`
+	Title      string `json:"title"`
+	Content    string `json:"content" binding:"required"`
+	Mode       string `json:"mode"`                 // 润色模式，为空时使用 polish
+	WordLimit  int    `json:"wordLimit,omitempty"`  // condense 模式的目标字数
+	GradeLevel int    `json:"gradeLevel,omitempty"` // 作者年级（1-12），为 0 时使用登录用户资料中的年级
 }
 
 // EssayResponse 作文润色响应结构
 type EssayResponse struct {
-	Title           string `json:"title"`
-	PolishedContent string `json:"polishedContent"`
+	Title           string         `json:"title"`
+	PolishedContent string         `json:"polishedContent"`
+	Mode            string         `json:"mode"`                 // 实际使用的润色模式
+	GradeLevel      int            `json:"gradeLevel,omitempty"` // 实际使用的年级
+	Provider        string         `json:"provider"`             // 实际完成润色的AI服务提供方
+	Feedback        *EssayFeedback `json:"feedback,omitempty"`   // 结构化反馈，提供方不支持时为空
+	Changes         []TextChange   `json:"changes"`              // 原文与润色结果之间的修改
+	Usage           TokenUsage     `json:"usage"`
+	Cached          bool           `json:"cached"` // 是否直接返回了缓存的结果
+	Status          string         `json:"status"`
+}
+
+// IsValidPolishMode 判断润色模式是否受支持
+func IsValidPolishMode(mode string) bool {
+	switch mode {
+	case PolishModeProofread, PolishModePolish, PolishModeExpand, PolishModeCondense:
+		return true
+	}
+	return false
+}
+
+// IsValidGradeLevel 判断年级是否在 1-12 之间
+func IsValidGradeLevel(grade int) bool {
+	return grade >= 1 && grade <= 12
+}
+
+// EssayIssue 作文中的一处具体问题及修改建议
+type EssayIssue struct {
+	Type        string `json:"type"`        // 问题类型: typo、punctuation、grammar、wording、structure、detail
+	Original    string `json:"original"`    // 原文片段
+	Suggestion  string `json:"suggestion"`  // 修改后的片段
+	Explanation string `json:"explanation"` // 修改原因
+}
+
+// EssayFeedback AI返回的结构化反馈
+type EssayFeedback struct {
+	PolishedContent string       `json:"polishedContent"`
+	Issues          []EssayIssue `json:"issues"`
+	Strengths       []string     `json:"strengths"`
+	Comment         string       `json:"comment"` // 总体评语
+}
+
+// BatchPolishRequest 批量润色请求结构
+type BatchPolishRequest struct {
+	Items []EssayRequest `json:"items" binding:"required"`
+}
+
+// BatchItemResult 批量润色中单篇作文的处理结果
+type BatchItemResult struct {
+	Index    int        `json:"index"` // 在请求 items 中的下标
+	Title    string     `json:"title"`
+	Status   string     `json:"status"` // succeeded 或 failed
+	EssayID  int64      `json:"essayId,omitempty"`
+	Provider string     `json:"provider,omitempty"`
+	Usage    TokenUsage `json:"usage"`
+	Cached   bool       `json:"cached"`
+	Error    string     `json:"error,omitempty"`
 }
@@ -0,0 +1,40 @@
+package models
+
+// PolishJobStatus 异步润色任务的状态
+type PolishJobStatus string
+
+const (
+	PolishJobQueued    PolishJobStatus = "queued"    // 排队中
+	PolishJobRunning   PolishJobStatus = "running"   // 润色中
+	PolishJobSucceeded PolishJobStatus = "succeeded" // 已完成
+	PolishJobFailed    PolishJobStatus = "failed"    // 失败
+	PolishJobCanceled  PolishJobStatus = "canceled"  // 已取消
+)
+
+// PolishJobResult 异步润色任务的结果，字段与 /api/polish 的响应一致
+type PolishJobResult struct {
+	PolishedContent string         `json:"polishedContent"`
+	Provider        string         `json:"provider"`
+	Feedback        *EssayFeedback `json:"feedback"`
+	Changes         []TextChange   `json:"changes"`
+	Usage           TokenUsage     `json:"usage"`
+	Cached          bool           `json:"cached"`
+}
+
+// PolishJob 异步润色任务
+type PolishJob struct {
+	ID         string           `json:"id"`
+	Username   string           `json:"username,omitempty"` // 提交任务的用户，未登录时为空
+	Status     PolishJobStatus  `json:"status"`
+	Request    EssayRequest     `json:"request"`
+	Result     *PolishJobResult `json:"result,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  string           `json:"createdAt"`
+	StartedAt  string           `json:"startedAt,omitempty"`
+	FinishedAt string           `json:"finishedAt,omitempty"`
+}
+
+// IsFinished 判断任务是否已结束（完成、失败或取消）
+func (j *PolishJob) IsFinished() bool {
+	return j.Status == PolishJobSucceeded || j.Status == PolishJobFailed || j.Status == PolishJobCanceled
+}
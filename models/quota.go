@@ -0,0 +1,27 @@
+package models
+
+// QuotaLimits 配额限制，0 表示不限制
+type QuotaLimits struct {
+	RequestsPerDay   int `json:"requestsPerDay,omitempty"`
+	RequestsPerMonth int `json:"requestsPerMonth,omitempty"`
+	TokensPerDay     int `json:"tokensPerDay,omitempty"`
+	TokensPerMonth   int `json:"tokensPerMonth,omitempty"`
+}
+
+// QuotaConfig 配额配置文件的内容
+//
+// Users 中配置的用户使用自己的限制（整体替换角色的限制），其余用户使用所属角色的限制，
+// 角色未配置时不限制。
+type QuotaConfig struct {
+	Roles map[string]QuotaLimits `json:"roles"`
+	Users map[string]QuotaLimits `json:"users"`
+}
+
+// QuotaStatus 某一项配额的使用情况
+type QuotaStatus struct {
+	Name      string `json:"name"` // requestsPerDay、requestsPerMonth、tokensPerDay 或 tokensPerMonth
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+	ResetAt   string `json:"resetAt"` // 配额重置时间（RFC3339）
+}
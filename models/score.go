@@ -0,0 +1,52 @@
+package models
+
+// RubricCriterion 评分标准中的一项
+type RubricCriterion struct {
+	Key         string `json:"key"`         // 例如 content、structure、language、creativity、mechanics
+	Name        string `json:"name"`        // 显示名称，例如 "内容"
+	Description string `json:"description"` // 评分要点
+	MaxScore    int    `json:"maxScore"`
+}
+
+// Rubric 作文评分标准
+type Rubric struct {
+	Name     string            `json:"name"`
+	Criteria []RubricCriterion `json:"criteria"`
+}
+
+// ScoreRequest 作文评分请求结构
+type ScoreRequest struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`              // 指定 essayId 时忽略，使用已保存作文的正文
+	GradeLevel int    `json:"gradeLevel,omitempty"` // 作者年级（1-12），为 0 时使用登录用户资料中的年级
+	EssayID    int64  `json:"essayId,omitempty"`    // 登录用户可指定已保存的作文，评分结果会记录到该作文上
+}
+
+// CriterionScore 单项得分
+type CriterionScore struct {
+	Key           string `json:"key" dynamodbav:"key"`
+	Name          string `json:"name" dynamodbav:"name"`
+	Score         int    `json:"score" dynamodbav:"score"`
+	MaxScore      int    `json:"maxScore" dynamodbav:"maxScore"`
+	Justification string `json:"justification" dynamodbav:"justification"` // 给分理由
+}
+
+// EssayScore 作文评分结果
+type EssayScore struct {
+	Total    int              `json:"total" dynamodbav:"total"`
+	MaxTotal int              `json:"maxTotal" dynamodbav:"maxTotal"`
+	Criteria []CriterionScore `json:"criteria" dynamodbav:"criteria"`
+	Comment  string           `json:"comment" dynamodbav:"comment"`
+	Rubric   string           `json:"rubric" dynamodbav:"rubric"`     // 使用的评分标准名称
+	Provider string           `json:"provider" dynamodbav:"provider"` // 完成评分的AI服务提供方
+	ScoredAt string           `json:"scoredAt" dynamodbav:"scoredAt"`
+}
+
+// ScoreTrendPoint 分数趋势中的一个点
+type ScoreTrendPoint struct {
+	EssayID  int64  `json:"essayId"`
+	Title    string `json:"title"`
+	Total    int    `json:"total"`
+	MaxTotal int    `json:"maxTotal"`
+	ScoredAt string `json:"scoredAt"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// 交互式润色会话中客户端发送的消息类型
+const (
+	SessionMessagePolish   = "polish"   // 提交作文，开始新的会话
+	SessionMessageResume   = "resume"   // 以页面上已有的润色结果继续会话，不调用AI服务
+	SessionMessageFollowUp = "followup" // 针对上一次的结果提出修改要求
+	SessionMessageCancel   = "cancel"   // 中止正在进行的输出
+)
+
+// SessionClientMessage 交互式润色会话中客户端发送的消息
+type SessionClientMessage struct {
+	Type string `json:"type"`
+	EssayRequest
+	PolishedContent string `json:"polishedContent,omitempty"` // resume 时已有的润色结果
+	Instruction     string `json:"instruction,omitempty"`     // followup 时的修改要求
+}
+
+// SessionServerMessage 交互式润色会话中服务端发送的消息
+//
+// 每次润色依次发送 start、若干 delta 和 done，失败或被取消时以 error 结束；
+// resume 成功后发送 ready。
+type SessionServerMessage struct {
+	Type     string       `json:"type"` // ready、start、delta、done、error
+	Turn     int          `json:"turn"` // 第几次修改，首次润色为 0
+	Content  string       `json:"content,omitempty"`
+	Provider string       `json:"provider,omitempty"`
+	Changes  []TextChange `json:"changes,omitempty"` // 相对上一版本的修改之处
+	Usage    *TokenUsage  `json:"usage,omitempty"`
+	Cached   bool         `json:"cached,omitempty"`
+	Code     int          `json:"code,omitempty"` // 出错时的状态码，与HTTP接口一致
+}
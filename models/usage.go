@@ -0,0 +1,34 @@
+package models
+
+// TokenUsage AI调用消耗的token数
+type TokenUsage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// Add 累加另一次调用的用量
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// UsageRecord 某个用户某天在某个模型上的累计用量
+type UsageRecord struct {
+	Username string `json:"username"` // 未登录用户为空
+	Date     string `json:"date"`     // 格式为 2006-01-02
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	TokenUsage
+	Cost float64 `json:"cost"` // 按配置的模型价格计算的费用（元），未配置价格的模型为 0
+}
+
+// UsageSummary 按用户、日期或模型汇总的用量
+type UsageSummary struct {
+	Key      string `json:"key"`
+	Requests int    `json:"requests"`
+	TokenUsage
+	Cost float64 `json:"cost"`
+}
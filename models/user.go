@@ -1,10 +1,18 @@
 package models
 
+// 用户角色，配额按角色配置；认证文件中可以用 role=<角色> 指定其他角色
+const (
+	RoleAnonymous = "anonymous" // 未登录用户
+	RoleUser      = "user"      // 未指定角色的登录用户
+)
+
 // User 表示系统用户
 type User struct {
-	Username string `json:"username"`
-	Password string `json:"-"` // 不在JSON中返回密码
-	LoggedIn bool   `json:"loggedIn"`
+	Username   string `json:"username"`
+	Password   string `json:"-"` // 不在JSON中返回密码
+	LoggedIn   bool   `json:"loggedIn"`
+	GradeLevel int    `json:"gradeLevel,omitempty"` // 年级（1-12），润色时作为默认年级
+	Role       string `json:"role"`
 }
 
 // Essay 表示一篇作文，适应 DynamoDB 表结构
@@ -17,4 +25,7 @@ type Essay struct {
 	OriginalContent string `json:"originalContent" dynamodbav:"originalContent"`
 	PolishedContent string `json:"polishedContent" dynamodbav:"polishedContent"`
 	ParentID        int64  `json:"parentId,omitempty" dynamodbav:"parentId,omitempty"` // 父版本的ID，用于跟踪版本关系
+	Version         int64  `json:"version" dynamodbav:"version"`                      // 版本号，每次写入加一，修改时用于检测其他设备的并发修改
+	Seq             int64  `json:"seq" dynamodbav:"seq,omitempty"`                     // 变更序号，用户的每次写入（包括删除）由服务器递增分配，作为增量同步的游标
+	Score           *EssayScore `json:"score,omitempty" dynamodbav:"score,omitempty"`     // 最近一次评分结果
 }
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"essay-go/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry 缓存中的一条润色结果
+type cacheEntry struct {
+	key       string
+	result    PolishResult
+	expiresAt time.Time
+}
+
+// inflightPolish 正在进行中的相同请求，后到的请求等待它完成后复用结果
+type inflightPolish struct {
+	done   chan struct{}
+	result *PolishResult
+	err    error
+}
+
+// CachedAIService 润色结果缓存
+//
+// 以提供方回退链、模型、提示词模板版本、润色参数和作文内容的哈希为键，缓存成功的润色结果，
+// 重复提交同一篇作文（双击、刷新页面）时直接返回缓存，不再调用AI服务。
+// 缓存按最近使用淘汰，条目超过 ttl 后失效；同时进行的相同请求只调用一次AI服务。
+// 首选提供方冷却期间由后备提供方给出的结果不缓存，首选提供方恢复后不会继续返回后备的结果。
+type CachedAIService struct {
+	inner      AIService
+	primary    string // 回退链中的首选提供方，只缓存完全由它给出的结果
+	models     string // 各提供方配置的默认模型，模型配置变化时缓存随之失效
+	maxEntries int
+	ttl        time.Duration
+
+	mutex    sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // 最近使用的在前
+	inflight map[string]*inflightPolish
+}
+
+// NewCachedAIService 创建润色结果缓存，maxEntries 或 ttl 不大于 0 时不缓存，直接返回 inner
+//
+// providerModels 为各提供方名称到默认模型的映射，参与缓存键的计算。
+func NewCachedAIService(inner AIService, providerModels map[string]string, maxEntries int, ttl time.Duration) AIService {
+	if maxEntries <= 0 || ttl <= 0 {
+		return inner
+	}
+	return &CachedAIService{
+		inner:      inner,
+		primary:    strings.Split(inner.Name(), ",")[0],
+		models:     formatModels(providerModels),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		inflight:   make(map[string]*inflightPolish),
+	}
+}
+
+// Name 返回内部服务的名称
+func (s *CachedAIService) Name() string {
+	return s.inner.Name()
+}
+
+// PolishEssay 润色作文，命中缓存时直接返回缓存的结果
+func (s *CachedAIService) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	key := s.cacheKey(req)
+	if result := s.get(key); result != nil {
+		fmt.Printf("[CachedAIService] 命中缓存, 提供方: %s\n", result.Provider)
+		return result, nil
+	}
+
+	// 相同的请求正在进行时等待其结果，失败时自己再请求一次
+	s.mutex.Lock()
+	if call, exists := s.inflight[key]; exists {
+		s.mutex.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err == nil {
+			return cachedCopy(call.result), nil
+		}
+		return s.inner.PolishEssay(ctx, req)
+	}
+	call := &inflightPolish{done: make(chan struct{})}
+	s.inflight[key] = call
+	s.mutex.Unlock()
+
+	call.result, call.err = s.inner.PolishEssay(ctx, req)
+	if call.err == nil {
+		s.put(key, call.result)
+	}
+
+	s.mutex.Lock()
+	delete(s.inflight, key)
+	s.mutex.Unlock()
+	close(call.done)
+	return call.result, call.err
+}
+
+// PolishEssayStream 流式润色作文，命中缓存时一次性输出缓存的结果
+func (s *CachedAIService) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	key := s.cacheKey(req)
+	if result := s.get(key); result != nil {
+		fmt.Printf("[CachedAIService] 命中缓存, 提供方: %s\n", result.Provider)
+		if err := onDelta(result.Content); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	result, err := s.inner.PolishEssayStream(ctx, req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+	s.put(key, result)
+	return result, nil
+}
+
+// ScoreEssay 评分不缓存，直接交给内部服务
+func (s *CachedAIService) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	return s.inner.ScoreEssay(ctx, req, rubric)
+}
+
+// cacheKey 计算缓存键，包含所有会影响润色结果的参数
+func (s *CachedAIService) cacheKey(req *PolishRequest) string {
+	version := ""
+	if store := GetPromptStore(); store != nil {
+		if prompt, err := store.Render(req); err == nil {
+			version = prompt.Version + "|" + formatModels(prompt.models)
+		}
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{
+		s.inner.Name(),
+		s.models,
+		version,
+		req.Mode,
+		strconv.Itoa(req.GradeLevel),
+		strconv.Itoa(req.WordLimit),
+		strconv.FormatBool(req.Structured),
+		req.Title,
+		req.Content,
+	} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	for _, message := range req.History {
+		for _, part := range []string{message.Role, message.Content} {
+			hash.Write([]byte(part))
+			hash.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// get 返回未过期的缓存结果的副本，未命中时返回 nil
+func (s *CachedAIService) get(key string) *PolishResult {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, exists := s.entries[key]
+	if !exists {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil
+	}
+	s.order.MoveToFront(element)
+	return cachedCopy(&entry.result)
+}
+
+// put 保存润色结果，超过容量时淘汰最久未使用的条目；不是由首选提供方给出的结果不保存
+func (s *CachedAIService) put(key string, result *PolishResult) {
+	// 分段润色的结果中各段的提供方以逗号分隔，只要有一段来自后备提供方就不缓存
+	if result.Provider != s.primary {
+		fmt.Printf("[CachedAIService] 结果来自后备提供方 %s，不缓存\n", result.Provider)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &cacheEntry{key: key, result: *result, expiresAt: time.Now().Add(s.ttl)}
+	if element, exists := s.entries[key]; exists {
+		element.Value = entry
+		s.order.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.order.PushFront(entry)
+
+	for s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cachedCopy 返回标记为来自缓存的结果副本，缓存结果不再消耗token
+func cachedCopy(result *PolishResult) *PolishResult {
+	copied := *result
+	copied.Cached = true
+	copied.Usage = models.TokenUsage{}
+	return &copied
+}
+
+// formatModels 把提供方到模型的映射格式化为稳定的字符串
+func formatModels(providerModels map[string]string) string {
+	pairs := make([]string, 0, len(providerModels))
+	for provider, model := range providerModels {
+		pairs = append(pairs, provider+"="+model)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
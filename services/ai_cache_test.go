@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"essay-go/models"
+)
+
+// countingService 记录润色调用次数，release 不为 nil 时等它关闭后才返回
+type countingService struct {
+	name     string
+	provider string // 结果中的提供方，为空时使用 name
+	err      error
+	release  chan struct{}
+	calls    atomic.Int32
+}
+
+func (s *countingService) Name() string {
+	return s.name
+}
+
+func (s *countingService) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	provider := s.provider
+	if provider == "" {
+		provider = s.name
+	}
+	return &PolishResult{
+		Content:  req.Content + "（已润色）",
+		Provider: provider,
+		Usage:    models.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (s *countingService) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	return polishOnce(ctx, s, req, onDelta)
+}
+
+func (s *countingService) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	return nil, ErrUnsupported
+}
+
+func newTestRequest(mode string) *PolishRequest {
+	return NewPolishRequest(models.EssayRequest{Title: "春天", Content: "春天来了。", Mode: mode})
+}
+
+// usePromptDir 把提示词模板复制到临时目录并作为全局模板，返回该目录
+func usePromptDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files, err := filepath.Glob(filepath.Join("..", "prompts", "*.tmpl"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("未找到提示词模板: %v", err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(file)), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := promptStore
+	t.Cleanup(func() { promptStore = previous })
+	if err := InitPromptStore(dir, 0); err != nil {
+		t.Fatalf("加载提示词模板失败: %v", err)
+	}
+	return dir
+}
+
+func TestCachedAIServiceKey(t *testing.T) {
+	dir := usePromptDir(t)
+	inner := &countingService{name: "deepseek"}
+	service := NewCachedAIService(inner, map[string]string{"deepseek": "deepseek-chat"}, 10, time.Hour)
+	ctx := context.Background()
+
+	polish := func(req *PolishRequest, wantCalls int32, wantCached bool) {
+		t.Helper()
+		result, err := service.PolishEssay(ctx, req)
+		if err != nil {
+			t.Fatalf("PolishEssay 失败: %v", err)
+		}
+		if got := inner.calls.Load(); got != wantCalls {
+			t.Errorf("调用了 %d 次AI服务, 期望 %d 次", got, wantCalls)
+		}
+		if result.Cached != wantCached {
+			t.Errorf("Cached = %v, 期望 %v", result.Cached, wantCached)
+		}
+		if wantCached && result.Usage.TotalTokens != 0 {
+			t.Errorf("缓存结果的token用量 = %d, 期望 0", result.Usage.TotalTokens)
+		}
+	}
+
+	polish(newTestRequest(models.PolishModePolish), 1, false)
+	polish(newTestRequest(models.PolishModePolish), 1, true)
+
+	// 润色模式不同
+	polish(newTestRequest(models.PolishModeProofread), 2, false)
+	polish(newTestRequest(models.PolishModeProofread), 2, true)
+
+	// 年级不同
+	req := newTestRequest(models.PolishModePolish)
+	req.GradeLevel = 3
+	polish(req, 3, false)
+
+	// 修改模板后版本变化，旧的缓存不再命中
+	path := filepath.Join(dir, "polish.tmpl")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(content, "\n请保持原文的段落结构。\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := promptStore.reload(); err != nil {
+		t.Fatalf("重新加载提示词模板失败: %v", err)
+	}
+	polish(newTestRequest(models.PolishModePolish), 4, false)
+	polish(newTestRequest(models.PolishModePolish), 4, true)
+	// 其他模式的模板没有变化
+	polish(newTestRequest(models.PolishModeProofread), 4, true)
+
+	// 模型配置不同的服务不共用缓存键
+	other := NewCachedAIService(inner, map[string]string{"deepseek": "deepseek-reasoner"}, 10, time.Hour).(*CachedAIService)
+	req = newTestRequest(models.PolishModePolish)
+	if service.(*CachedAIService).cacheKey(req) == other.cacheKey(req) {
+		t.Error("模型配置不同时缓存键相同")
+	}
+}
+
+func TestCachedAIServiceExpiry(t *testing.T) {
+	inner := &countingService{name: "deepseek"}
+	service := NewCachedAIService(inner, nil, 2, time.Hour).(*CachedAIService)
+	ctx := context.Background()
+
+	service.PolishEssay(ctx, newTestRequest(models.PolishModePolish))
+	service.PolishEssay(ctx, newTestRequest(models.PolishModePolish))
+	if got := inner.calls.Load(); got != 1 {
+		t.Fatalf("调用了 %d 次AI服务, 期望 1 次", got)
+	}
+
+	// 超过有效期
+	service.mutex.Lock()
+	for _, element := range service.entries {
+		element.Value.(*cacheEntry).expiresAt = time.Now().Add(-time.Second)
+	}
+	service.mutex.Unlock()
+	service.PolishEssay(ctx, newTestRequest(models.PolishModePolish))
+	if got := inner.calls.Load(); got != 2 {
+		t.Errorf("缓存过期后调用了 %d 次AI服务, 期望 2 次", got)
+	}
+
+	// 超过容量时淘汰最久未使用的条目
+	service.PolishEssay(ctx, newTestRequest(models.PolishModeProofread))
+	service.PolishEssay(ctx, newTestRequest(models.PolishModeExpand))
+	if len(service.entries) != 2 || service.order.Len() != 2 {
+		t.Errorf("缓存有 %d 条, 期望 2 条", len(service.entries))
+	}
+	service.PolishEssay(ctx, newTestRequest(models.PolishModePolish))
+	if got := inner.calls.Load(); got != 5 {
+		t.Errorf("被淘汰的条目调用了 %d 次AI服务, 期望 5 次", got)
+	}
+}
+
+func TestCachedAIServiceNotCached(t *testing.T) {
+	ctx := context.Background()
+
+	// 后备提供方给出的结果
+	fallback := &countingService{name: "deepseek,openai", provider: "openai"}
+	service := NewCachedAIService(fallback, nil, 10, time.Hour)
+	service.PolishEssay(ctx, newTestRequest(models.PolishModePolish))
+	service.PolishEssay(ctx, newTestRequest(models.PolishModePolish))
+	if got := fallback.calls.Load(); got != 2 {
+		t.Errorf("后备提供方的结果被缓存, 调用了 %d 次AI服务", got)
+	}
+
+	// 失败的请求
+	failing := &countingService{name: "deepseek", err: errors.New("服务不可用")}
+	service = NewCachedAIService(failing, nil, 10, time.Hour)
+	for i := 0; i < 2; i++ {
+		if _, err := service.PolishEssay(ctx, newTestRequest(models.PolishModePolish)); err == nil {
+			t.Fatal("期望返回错误")
+		}
+	}
+	if got := failing.calls.Load(); got != 2 {
+		t.Errorf("失败的结果被缓存, 调用了 %d 次AI服务", got)
+	}
+}
+
+func TestCachedAIServiceInflight(t *testing.T) {
+	inner := &countingService{name: "deepseek", release: make(chan struct{})}
+	service := NewCachedAIService(inner, nil, 10, time.Hour)
+
+	const requests = 10
+	var wg sync.WaitGroup
+	results := make([]*PolishResult, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := service.PolishEssay(context.Background(), newTestRequest(models.PolishModePolish))
+			if err != nil {
+				t.Errorf("PolishEssay 失败: %v", err)
+				return
+			}
+			results[i] = result
+		}(i)
+	}
+
+	// 等所有请求都到达后再让第一个请求完成
+	time.Sleep(50 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if got := inner.calls.Load(); got != 1 {
+		t.Errorf("%d 个相同的请求调用了 %d 次AI服务, 期望 1 次", requests, got)
+	}
+	fresh := 0
+	for _, result := range results {
+		if result != nil && !result.Cached {
+			fresh++
+		}
+	}
+	if fresh != 1 {
+		t.Errorf("%d 个结果不是来自缓存, 期望 1 个", fresh)
+	}
+}
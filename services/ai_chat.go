@@ -0,0 +1,427 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"essay-go/config"
+	"essay-go/models"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// deepSeekEndpoint DeepSeek API端点
+const deepSeekEndpoint = "https://api.deepseek.com/v1/chat/completions"
+
+func init() {
+	RegisterProvider("deepseek", func(cfg *config.Config) (AIService, error) {
+		if cfg.DeepSeekAPIKey == "" {
+			return nil, errors.New("未配置 DEEPSEEK_API_KEY")
+		}
+		model := cfg.DeepSeekModel
+		if model == "" {
+			model = "deepseek-chat"
+		}
+		return &chatProvider{
+			name:     "deepseek",
+			label:    "DeepSeek",
+			endpoint: deepSeekEndpoint,
+			apiKey:   cfg.DeepSeekAPIKey,
+			model:    model,
+			retry:    retryPolicyFromConfig(cfg),
+		}, nil
+	})
+
+	// 任意兼容 OpenAI /v1/chat/completions 协议的服务，例如本地 Ollama
+	RegisterProvider("openai", func(cfg *config.Config) (AIService, error) {
+		if cfg.OpenAIEndpoint == "" || cfg.OpenAIModel == "" {
+			return nil, errors.New("未配置 OPENAI_ENDPOINT 或 OPENAI_MODEL")
+		}
+		return &chatProvider{
+			name:     "openai",
+			label:    "OpenAI兼容服务",
+			endpoint: cfg.OpenAIEndpoint,
+			apiKey:   cfg.OpenAIAPIKey,
+			model:    cfg.OpenAIModel,
+			retry:    retryPolicyFromConfig(cfg),
+		}, nil
+	})
+}
+
+// chatProvider OpenAI 兼容的 chat completions 服务（DeepSeek 也使用该协议）
+type chatProvider struct {
+	name     string
+	label    string // 日志和错误信息中使用的名称
+	endpoint string
+	apiKey   string
+	model    string
+	retry    RetryPolicy
+}
+
+// Name 返回提供方名称
+func (p *chatProvider) Name() string {
+	return p.name
+}
+
+// renderPrompt 渲染润色参数对应模式的提示词模板
+func (p *chatProvider) renderPrompt(req *PolishRequest) (*RenderedPrompt, error) {
+	store := GetPromptStore()
+	if store == nil {
+		return nil, errors.New("提示词模板未初始化")
+	}
+	return store.Render(req)
+}
+
+// newRequest 构造 chat completions 请求，history 为首次提示词之后的对话，
+// jsonMode 为 true 时要求返回JSON，stream 为 true 时请求SSE流式响应
+func (p *chatProvider) newRequest(ctx context.Context, prompt *RenderedPrompt, history []ChatMessage, jsonMode, stream bool) (*http.Request, error) {
+	// 模板可以覆盖模型和生成参数
+	model := prompt.ModelFor(p.name, p.model)
+	fmt.Printf("[chatProvider] 调用%s, 端点: %s, 模型: %s, 模板版本: %s\n", p.label, p.endpoint, model, prompt.Version)
+
+	// 准备请求数据，交互式润色时带上之前的对话
+	messages := []ChatMessage{{Role: "user", Content: prompt.Text}}
+	messages = append(messages, history...)
+	requestData := map[string]interface{}{
+		"model":       model,
+		"messages":    messages,
+		"temperature": prompt.Temperature,
+		"max_tokens":  prompt.MaxTokens,
+		"stream":      stream,
+	}
+	if stream {
+		// 让服务端在最后一个数据块中返回token用量
+		requestData["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	if jsonMode {
+		requestData["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	jsonData, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+
+	// 创建HTTP请求
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	// 设置请求头
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return httpReq, nil
+}
+
+// PolishEssay 使用 chat completions 接口润色作文
+func (p *chatProvider) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	prompt, err := p.renderPrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	polishedContent, usage, err := p.complete(ctx, req, prompt, req.Structured)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[chatProvider] 润色成功，润色后内容长度: %d字符\n", len(polishedContent))
+	return applyFeedback(req, &PolishResult{Content: polishedContent, Provider: p.name, Usage: usage}), nil
+}
+
+// ScoreEssay 使用 chat completions 接口按评分标准给作文打分
+func (p *chatProvider) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	store := GetPromptStore()
+	if store == nil {
+		return nil, errors.New("提示词模板未初始化")
+	}
+	prompt, err := store.RenderScore(req, rubric)
+	if err != nil {
+		return nil, err
+	}
+
+	content, _, err := p.complete(ctx, req, prompt, true)
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := parseScore(content, rubric)
+	if err != nil {
+		return nil, err
+	}
+	score.Provider = p.name
+	return score, nil
+}
+
+// chatUsage 响应中的 usage 字段
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// record 把用量记到发起请求的用户名下，并返回对应的 models.TokenUsage
+func (u *chatUsage) record(req *PolishRequest, provider, model string) models.TokenUsage {
+	if u == nil {
+		return models.TokenUsage{}
+	}
+	usage := models.TokenUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	fmt.Printf("[chatProvider] token用量: 输入 %d, 输出 %d, 模型: %s, 用户: %s\n", usage.PromptTokens, usage.CompletionTokens, model, req.Username)
+	recordUsage(req.Username, provider, model, usage)
+	return usage
+}
+
+// estimateChatUsage 按字数估算一次调用的用量（中文大约每字一个token），用于没有收到 usage 的流式响应
+func estimateChatUsage(prompt *RenderedPrompt, history []ChatMessage, output string) *chatUsage {
+	promptTokens := utf8.RuneCountInString(prompt.Text)
+	for _, message := range history {
+		promptTokens += utf8.RuneCountInString(message.Content)
+	}
+	completionTokens := utf8.RuneCountInString(output)
+	return &chatUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// complete 发送非流式请求，返回模型输出的文本和token用量（已计入用量统计）
+func (p *chatProvider) complete(ctx context.Context, req *PolishRequest, prompt *RenderedPrompt, jsonMode bool) (string, models.TokenUsage, error) {
+	var usage models.TokenUsage
+	httpReq, err := p.newRequest(ctx, prompt, req.History, jsonMode, false)
+	if err != nil {
+		fmt.Printf("[chatProvider] %v\n", err)
+		return "", usage, err
+	}
+
+	// 发送请求
+	client := &http.Client{Timeout: 60 * time.Second}
+	fmt.Println("[chatProvider] 开始发送HTTP请求...")
+	resp, err := doWithRetry(client, httpReq, p.retry)
+	if err != nil {
+		fmt.Printf("[chatProvider] 发送%s请求失败: %v\n", p.label, err)
+		return "", usage, &ProviderError{Provider: p.name, Retryable: true, Err: fmt.Errorf("发送%s请求失败: %w", p.label, err)}
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	fmt.Printf("[chatProvider] 收到响应，状态码: %d\n", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		// 读取错误响应体
+		var errorResponse map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err == nil {
+			fmt.Printf("[chatProvider] %s API错误响应: %v\n", p.label, errorResponse)
+			return "", usage, newStatusError(p.name, resp.StatusCode, fmt.Errorf("%s API错误: %v", p.label, errorResponse))
+		}
+		fmt.Printf("[chatProvider] %s API返回错误状态码: %d\n", p.label, resp.StatusCode)
+		return "", usage, newStatusError(p.name, resp.StatusCode, fmt.Errorf("%s API返回错误状态码: %d", p.label, resp.StatusCode))
+	}
+
+	// 读取响应体
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("[chatProvider] 读取%s响应体失败: %v\n", p.label, err)
+		return "", usage, &ProviderError{Provider: p.name, Retryable: true, Err: fmt.Errorf("读取%s响应体失败: %w", p.label, err)}
+	}
+
+	// 打印响应体（截断版本以防止日志过长）
+	const maxLogResponseBodyLength = 1024 // 允许记录的最大响应体长度
+	responseBodyStr := string(respBody)
+	if len(responseBodyStr) > maxLogResponseBodyLength {
+		fmt.Printf("[chatProvider] %s API响应 (truncated to %d chars): %s...\n", p.label, maxLogResponseBodyLength, responseBodyStr[:maxLogResponseBodyLength])
+	} else {
+		fmt.Printf("[chatProvider] %s API响应: %s\n", p.label, responseBodyStr)
+	}
+
+	// 解析响应
+	var result map[string]interface{}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		fmt.Printf("[chatProvider] 解析%s响应失败: %v\n", p.label, err)
+		return "", usage, fmt.Errorf("解析%s响应失败: %w", p.label, err)
+	}
+
+	// 记录token用量，模型以响应中的为准
+	var usageResponse struct {
+		Model string     `json:"model"`
+		Usage *chatUsage `json:"usage"`
+	}
+	json.Unmarshal(respBody, &usageResponse) // 已确认是合法JSON，用量缺失时忽略
+	model := usageResponse.Model
+	if model == "" {
+		model = prompt.ModelFor(p.name, p.model)
+	}
+	usage = usageResponse.Usage.record(req, p.name, model)
+
+	// 获取润色后的内容
+	choices, ok := result["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return "", usage, fmt.Errorf("%s响应中未找到有效的choices", p.label)
+	}
+
+	firstChoice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return "", usage, fmt.Errorf("%s响应中的choice格式无效", p.label)
+	}
+
+	message, ok := firstChoice["message"].(map[string]interface{})
+	if !ok {
+		return "", usage, fmt.Errorf("%s响应中的message格式无效", p.label)
+	}
+
+	content, ok := message["content"].(string)
+	if !ok {
+		return "", usage, fmt.Errorf("%s响应中未找到输出内容", p.label)
+	}
+
+	// 输出达到 max_tokens 上限时内容不完整，不能当作成功返回
+	if finishReason, _ := firstChoice["finish_reason"].(string); finishReason == "length" {
+		fmt.Printf("[chatProvider] %s输出被截断，已输出 %d 字符\n", p.label, len(content))
+		return "", usage, &ProviderError{Provider: p.name, Err: fmt.Errorf("%w（max_tokens=%d）", ErrOutputTruncated, prompt.MaxTokens)}
+	}
+	return content, usage, nil
+}
+
+// streamHTTPClient 流式请求使用的HTTP客户端
+//
+// 生成长作文可能超过一分钟，不能像非流式请求那样限制整个响应的读取时间；这里只限制等待响应头的时间，
+// 整体的截止时间由请求的 ctx 决定。
+var streamHTTPClient = newStreamHTTPClient(60 * time.Second)
+
+// newStreamHTTPClient 创建只限制等待响应头时间的HTTP客户端
+func newStreamHTTPClient(responseHeaderTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return &http.Client{Transport: transport}
+}
+
+// chatStreamChunk 流式响应中的单个数据块
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Model string     `json:"model"`
+	Usage *chatUsage `json:"usage"` // 请求 stream_options.include_usage 时在最后一个数据块中返回
+}
+
+// PolishEssayStream 使用SSE流式接口润色作文
+func (p *chatProvider) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	prompt, err := p.renderPrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := p.newRequest(ctx, prompt, req.History, req.Structured, true)
+	if err != nil {
+		fmt.Printf("[chatProvider] %v\n", err)
+		return nil, err
+	}
+
+	resp, err := doWithRetry(streamHTTPClient, httpReq, p.retry)
+	if err != nil {
+		fmt.Printf("[chatProvider] 发送%s流式请求失败: %v\n", p.label, err)
+		return nil, &ProviderError{Provider: p.name, Retryable: true, Err: fmt.Errorf("发送%s请求失败: %w", p.label, err)}
+	}
+	defer resp.Body.Close()
+
+	fmt.Printf("[chatProvider] 收到流式响应，状态码: %d\n", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(p.name, resp.StatusCode, fmt.Errorf("%s API返回错误状态码: %d, 响应: %s", p.label, resp.StatusCode, string(body)))
+	}
+
+	// 逐行解析SSE响应，每个事件形如 "data: {...}"，以 "data: [DONE]" 结束
+	var polished strings.Builder
+	var streamUsage *chatUsage
+	model := prompt.ModelFor(p.name, p.model)
+
+	// 客户端断开、响应格式错误或读取失败而提前返回时，也要记录已经消耗的token；
+	// 用量只在最后一个数据块中返回，没有收到时按已输出的内容估算
+	recorded := false
+	defer func() {
+		if recorded {
+			return
+		}
+		if streamUsage == nil && polished.Len() > 0 {
+			streamUsage = estimateChatUsage(prompt, req.History, polished.String())
+		}
+		streamUsage.record(req, p.name, model)
+	}()
+	truncated := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// 跳过空行和 ": keep-alive" 之类的注释行
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			fmt.Printf("[chatProvider] 解析数据块失败: %v, 数据: %s\n", err, data)
+			return nil, fmt.Errorf("解析%s流式响应失败: %w", p.label, err)
+		}
+		if chunk.Usage != nil {
+			streamUsage = chunk.Usage
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if chunk.Choices[0].FinishReason == "length" {
+			truncated = true
+		}
+		if chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		polished.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			fmt.Printf("[chatProvider] 转发增量内容失败，停止读取: %v\n", err)
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("[chatProvider] 读取%s流式响应失败: %v\n", p.label, err)
+		return nil, &ProviderError{Provider: p.name, Retryable: true, Err: fmt.Errorf("读取%s流式响应失败: %w", p.label, err)}
+	}
+	usage := streamUsage.record(req, p.name, model)
+	recorded = true
+	if truncated {
+		fmt.Printf("[chatProvider] %s流式输出被截断，已输出 %d 字符\n", p.label, polished.Len())
+		return nil, &ProviderError{Provider: p.name, Err: fmt.Errorf("%w（max_tokens=%d）", ErrOutputTruncated, prompt.MaxTokens)}
+	}
+
+	fmt.Printf("[chatProvider] 流式润色完成，润色后内容长度: %d字符\n", polished.Len())
+	return applyFeedback(req, &PolishResult{Content: polished.String(), Provider: p.name, Usage: usage}), nil
+}
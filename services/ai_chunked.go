@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"essay-go/models"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// paragraphSeparator 段落分隔：包含换行的一段空白（含全角空格缩进）
+var paragraphSeparator = regexp.MustCompile(`[\s\x{3000}]*\n[\s\x{3000}]*`)
+
+// sentenceEnds 超长段落按句子切分时使用的句末标点
+const sentenceEnds = "。！？!?；;…"
+
+// essayChunk 分段润色中的一段，separator 是原文中紧跟这段的分隔空白，重新拼接时原样保留
+type essayChunk struct {
+	text      string
+	separator string
+}
+
+// ChunkedAIService 长作文分段润色
+//
+// 正文超过 chunkSize 字时，按段落（必要时按句子）切分成若干段依次润色，每段附带已润色的前文和
+// 尚未润色的后文作为上下文，最后按原文的段落分隔重新拼接。未超过时直接交给内部服务处理。
+// 每段的截止时间由内部服务决定，整篇作文另有总截止时间。
+type ChunkedAIService struct {
+	inner       AIService
+	chunkSize   int           // 每段的最大字数
+	contextSize int           // 提供给相邻段落的上下文字数
+	timeout     time.Duration // 分段润色整篇作文的总截止时间，0 表示不限制
+}
+
+// NewChunkedAIService 创建分段润色服务，chunkSize 不大于 0 时不分段，直接返回 inner
+func NewChunkedAIService(inner AIService, chunkSize, contextSize int, timeout time.Duration) AIService {
+	if chunkSize <= 0 {
+		return inner
+	}
+	return &ChunkedAIService{inner: inner, chunkSize: chunkSize, contextSize: contextSize, timeout: timeout}
+}
+
+// Name 返回内部服务的名称
+func (s *ChunkedAIService) Name() string {
+	return s.inner.Name()
+}
+
+// PolishEssay 润色作文，长作文分段处理
+func (s *ChunkedAIService) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	prefix, chunks := splitEssayChunks(req.Content, s.chunkSize)
+	// 交互式润色需要整篇作文的对话上下文，不分段
+	if len(chunks) <= 1 || len(req.History) > 0 {
+		return s.inner.PolishEssay(ctx, req)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.polishChunks(req, prefix, chunks, func(chunkReq *PolishRequest) (*PolishResult, error) {
+		return s.inner.PolishEssay(ctx, chunkReq)
+	})
+}
+
+// PolishEssayStream 流式润色作文，长作文逐段输出
+//
+// 原文开头的空白、段与段之间的分隔和结尾的空白都按原文输出，拼接所有增量内容即为返回结果的 Content。
+func (s *ChunkedAIService) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	prefix, chunks := splitEssayChunks(req.Content, s.chunkSize)
+	if len(chunks) <= 1 || len(req.History) > 0 {
+		return s.inner.PolishEssayStream(ctx, req, onDelta)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if prefix != "" {
+		if err := onDelta(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	index := 0
+	result, err := s.polishChunks(req, prefix, chunks, func(chunkReq *PolishRequest) (*PolishResult, error) {
+		if index > 0 {
+			if err := onDelta(chunks[index-1].separator); err != nil {
+				return nil, err
+			}
+		}
+		index++
+
+		// 去掉每段输出首尾的空白，段落之间的分隔由原文决定；空白暂不输出，后面还有内容时再一起输出
+		started := false
+		pending := ""
+		return s.inner.PolishEssayStream(ctx, chunkReq, func(delta string) error {
+			if !started {
+				delta = strings.TrimLeftFunc(delta, unicode.IsSpace)
+				if delta == "" {
+					return nil
+				}
+				started = true
+			}
+			delta = pending + delta
+			trimmed := strings.TrimRightFunc(delta, unicode.IsSpace)
+			pending = delta[len(trimmed):]
+			if trimmed == "" {
+				return nil
+			}
+			return onDelta(trimmed)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if separator := chunks[len(chunks)-1].separator; separator != "" {
+		if err := onDelta(separator); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// withTimeout 为整篇作文的分段润色设置总截止时间
+func (s *ChunkedAIService) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// ScoreEssay 评分需要看到整篇作文，直接交给内部服务
+func (s *ChunkedAIService) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	return s.inner.ScoreEssay(ctx, req, rubric)
+}
+
+// polishChunks 依次润色每一段并拼接结果，任何一段失败（包括输出被截断）都返回错误
+func (s *ChunkedAIService) polishChunks(req *PolishRequest, prefix string, chunks []essayChunk, polish func(chunkReq *PolishRequest) (*PolishResult, error)) (*PolishResult, error) {
+	total := utf8.RuneCountInString(req.Content)
+	fmt.Printf("[ChunkedAIService] 作文共 %d 字，分 %d 段润色\n", total, len(chunks))
+
+	var polished strings.Builder
+	polished.WriteString(prefix)
+	var providers []string
+	var feedbacks []*models.EssayFeedback
+	var usage models.TokenUsage
+	for i, chunk := range chunks {
+		chunkReq := *req
+		chunkReq.Content = chunk.text
+		chunkReq.ContextBefore = lastRunes(strings.TrimSpace(polished.String()), s.contextSize)
+		chunkReq.ContextAfter = ""
+		if i+1 < len(chunks) {
+			chunkReq.ContextAfter = firstRunes(chunks[i+1].text, s.contextSize)
+		}
+		// 缩写的目标字数按每段占全文的比例分配
+		if req.Mode == models.PolishModeCondense && req.WordLimit > 0 {
+			chunkReq.WordLimit = max(1, req.WordLimit*utf8.RuneCountInString(chunk.text)/total)
+		}
+
+		result, err := polish(&chunkReq)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d/%d 段润色失败: %w", i+1, len(chunks), err)
+		}
+
+		polished.WriteString(strings.TrimSpace(result.Content))
+		polished.WriteString(chunk.separator)
+		if !containsString(providers, result.Provider) {
+			providers = append(providers, result.Provider)
+		}
+		usage.Add(result.Usage)
+		if result.Feedback != nil {
+			feedbacks = append(feedbacks, result.Feedback)
+		}
+	}
+
+	result := &PolishResult{
+		Content:  polished.String(),
+		Provider: strings.Join(providers, ","),
+		Usage:    usage,
+	}
+	if len(feedbacks) > 0 {
+		result.Feedback = mergeFeedback(result.Content, feedbacks)
+	}
+	return result, nil
+}
+
+// mergeFeedback 合并各段的结构化反馈：问题依次排列，优点去重，评语拼接
+func mergeFeedback(content string, feedbacks []*models.EssayFeedback) *models.EssayFeedback {
+	merged := &models.EssayFeedback{
+		PolishedContent: content,
+		Issues:          []models.EssayIssue{},
+		Strengths:       []string{},
+	}
+	var comments []string
+	for _, feedback := range feedbacks {
+		merged.Issues = append(merged.Issues, feedback.Issues...)
+		for _, strength := range feedback.Strengths {
+			if !containsString(merged.Strengths, strength) {
+				merged.Strengths = append(merged.Strengths, strength)
+			}
+		}
+		if comment := strings.TrimSpace(feedback.Comment); comment != "" && !containsString(comments, comment) {
+			comments = append(comments, comment)
+		}
+	}
+	merged.Comment = strings.Join(comments, "")
+	return merged
+}
+
+// splitEssayChunks 把正文切分为不超过 size 字的若干段，尽量在段落边界切分，超长段落按句子切分
+//
+// 返回正文开头的空白和切分结果，prefix + 每段的 text + separator 依次拼接即为原文。
+func splitEssayChunks(content string, size int) (prefix string, chunks []essayChunk) {
+	if utf8.RuneCountInString(content) <= size {
+		return "", []essayChunk{{text: content}}
+	}
+
+	// 先按段落切分，超长段落再切成句子组
+	var units []essayChunk
+	pos := 0
+	for _, loc := range paragraphSeparator.FindAllStringIndex(content, -1) {
+		if loc[0] == 0 {
+			prefix = content[:loc[1]]
+		} else {
+			units = append(units, splitSentences(content[pos:loc[0]], content[loc[0]:loc[1]], size)...)
+		}
+		pos = loc[1]
+	}
+	if pos < len(content) {
+		units = append(units, splitSentences(content[pos:], "", size)...)
+	}
+
+	// 再把相邻的小段合并，使每段尽量接近 size
+	var current essayChunk
+	currentSize := 0
+	for _, unit := range units {
+		unitSize := utf8.RuneCountInString(unit.text)
+		if currentSize > 0 && currentSize+utf8.RuneCountInString(current.separator)+unitSize > size {
+			chunks = append(chunks, current)
+			current, currentSize = essayChunk{}, 0
+		}
+		if currentSize > 0 {
+			current.text += current.separator
+			currentSize += utf8.RuneCountInString(current.separator)
+		}
+		current.text += unit.text
+		current.separator = unit.separator
+		currentSize += unitSize
+	}
+	if currentSize > 0 {
+		chunks = append(chunks, current)
+	}
+	return prefix, chunks
+}
+
+// splitSentences 把超过 size 字的段落切分为不超过 size 字的句子组（单个句子超长时按字数硬切），separator 归最后一组
+func splitSentences(paragraph, separator string, size int) []essayChunk {
+	if utf8.RuneCountInString(paragraph) <= size {
+		return []essayChunk{{text: paragraph, separator: separator}}
+	}
+
+	var pieces []essayChunk
+	var current []rune
+	runes := []rune(paragraph)
+	for i := 0; i < len(runes); i++ {
+		current = append(current, runes[i])
+		atSentenceEnd := strings.ContainsRune(sentenceEnds, runes[i])
+		// 句末标点后的引号属于同一句，但不能超过 size
+		for atSentenceEnd && len(current) < size && i+1 < len(runes) && strings.ContainsRune("”’」』\"')）", runes[i+1]) {
+			i++
+			current = append(current, runes[i])
+		}
+		if atSentenceEnd || len(current) >= size {
+			pieces = append(pieces, essayChunk{text: string(current)})
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, essayChunk{text: string(current)})
+	}
+
+	// 合并句子，使每组不超过 size
+	var groups []essayChunk
+	for _, piece := range pieces {
+		last := len(groups) - 1
+		if last >= 0 && utf8.RuneCountInString(groups[last].text)+utf8.RuneCountInString(piece.text) <= size {
+			groups[last].text += piece.text
+			continue
+		}
+		groups = append(groups, piece)
+	}
+	groups[len(groups)-1].separator = separator
+	return groups
+}
+
+// firstRunes 返回字符串的前 n 个字符
+func firstRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// lastRunes 返回字符串的最后 n 个字符
+func lastRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[len(runes)-n:])
+}
+
+// containsString 判断字符串切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
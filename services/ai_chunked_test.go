@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"essay-go/models"
+)
+
+func TestSplitEssayChunks(t *testing.T) {
+	paragraph := strings.Repeat("春天来了，小草从地下钻出来。", 5) // 70 字
+	tests := []struct {
+		name    string
+		content string
+		size    int
+		prefix  string
+		chunks  int
+	}{
+		{"不超过长度不切分", "　　今天天气很好。\n", 100, "", 1},
+		{"按段落切分", paragraph + "\n" + paragraph, 100, "", 2},
+		{"小段落合并", "第一段。\n第二段。\n第三段。\n" + paragraph, 80, "", 2},
+		{"开头的缩进和空行", "\n\n　　" + paragraph + "\n　　" + paragraph, 100, "\n\n　　", 2},
+		{"Windows换行", paragraph + "\r\n\r\n" + paragraph + "\r\n", 100, "", 2},
+		{"超长段落按句子切分", paragraph + paragraph, 50, "", 4},
+		{"没有标点的超长句子", strings.Repeat("春", 250), 100, "", 3},
+		{"中文引号和括号", strings.Repeat("他说：“我们走吧！”（笑）", 10) + "\n" + paragraph, 30, "", -1},
+		{"英文标点", strings.Repeat("It was a sunny day! Were you there? Yes; I was. ", 5), 40, "", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, chunks := splitEssayChunks(tt.content, tt.size)
+			if prefix != tt.prefix {
+				t.Errorf("prefix = %q, 期望 %q", prefix, tt.prefix)
+			}
+			if tt.chunks >= 0 && len(chunks) != tt.chunks {
+				t.Errorf("分为 %d 段, 期望 %d 段: %q", len(chunks), tt.chunks, chunks)
+			}
+
+			rebuilt := prefix
+			for i, chunk := range chunks {
+				if chunk.text == "" {
+					t.Errorf("第 %d 段为空", i+1)
+				}
+				if n := utf8.RuneCountInString(chunk.text); n > tt.size {
+					t.Errorf("第 %d 段有 %d 字, 超过 %d: %q", i+1, n, tt.size, chunk.text)
+				}
+				rebuilt += chunk.text + chunk.separator
+			}
+			if rebuilt != tt.content {
+				t.Errorf("拼接结果与原文不一致:\n得到 %q\n原文 %q", rebuilt, tt.content)
+			}
+		})
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name      string
+		paragraph string
+		separator string
+		size      int
+		want      []string
+	}{
+		{"不超过长度", "短句。", "\n", 10, []string{"短句。"}},
+		{"按句子合并", "一二三。四五六。七八九。", "\n\n", 8, []string{"一二三。四五六。", "七八九。"}},
+		{"感叹号问号分号", "好！对吗？是的；走…", "", 5, []string{"好！对吗？", "是的；走…"}},
+		{"超长句子按字数硬切", "一二三四五六七八九十", "", 4, []string{"一二三四", "五六七八", "九十"}},
+		{"引号属于前一句", "他说：“好。”我们走。", "", 8, []string{"他说：“好。”", "我们走。"}},
+		{"引号不超过长度", "他说：“好。”我们走。", "", 6, []string{"他说：“好。", "”我们走。"}},
+		{"英文", "Hi! How are you? Fine.", "\n", 10, []string{"Hi!", " How are y", "ou? Fine."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := splitSentences(tt.paragraph, tt.separator, tt.size)
+			var texts []string
+			rebuilt := ""
+			for i, group := range groups {
+				texts = append(texts, group.text)
+				rebuilt += group.text + group.separator
+				if i+1 < len(groups) && group.separator != "" {
+					t.Errorf("第 %d 组不是最后一组, 分隔为 %q", i+1, group.separator)
+				}
+				if n := utf8.RuneCountInString(group.text); n > tt.size {
+					t.Errorf("第 %d 组有 %d 字, 超过 %d: %q", i+1, n, tt.size, group.text)
+				}
+			}
+			if strings.Join(texts, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("splitSentences = %q, 期望 %q", texts, tt.want)
+			}
+			if rebuilt != tt.paragraph+tt.separator {
+				t.Errorf("拼接结果 %q, 期望 %q", rebuilt, tt.paragraph+tt.separator)
+			}
+		})
+	}
+}
+
+// echoService 把正文原样返回，并在首尾加上空白，流式输出时每次输出几个字
+type echoService struct{}
+
+func (s *echoService) Name() string {
+	return "echo"
+}
+
+func (s *echoService) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	return &PolishResult{Content: "\n " + req.Content + " \n", Provider: "echo"}, nil
+}
+
+func (s *echoService) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	result, _ := s.PolishEssay(ctx, req)
+	runes := []rune(result.Content)
+	for i := 0; i < len(runes); i += 3 {
+		if err := onDelta(string(runes[i:min(i+3, len(runes))])); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *echoService) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	return nil, ErrUnsupported
+}
+
+func TestChunkedPolishEssayStream(t *testing.T) {
+	paragraph := strings.Repeat("春天来了，小草 从地下钻出来。", 5)
+	content := "\n　　" + paragraph + "\n\n　　" + paragraph + "\r\n　　" + paragraph + "\n"
+	service := NewChunkedAIService(&echoService{}, 100, 20, 0)
+
+	var streamed strings.Builder
+	result, err := service.PolishEssayStream(context.Background(), NewPolishRequest(models.EssayRequest{Content: content}), func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("PolishEssayStream 失败: %v", err)
+	}
+	if result.Content != content {
+		t.Errorf("润色结果 %q, 期望与原文 %q 一致", result.Content, content)
+	}
+	if streamed.String() != result.Content {
+		t.Errorf("流式输出 %q 与润色结果 %q 不一致", streamed.String(), result.Content)
+	}
+
+	polished, err := service.PolishEssay(context.Background(), NewPolishRequest(models.EssayRequest{Content: content}))
+	if err != nil {
+		t.Fatalf("PolishEssay 失败: %v", err)
+	}
+	if polished.Content != result.Content {
+		t.Errorf("PolishEssay 结果 %q 与流式结果 %q 不一致", polished.Content, result.Content)
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnsupported 提供方不支持所请求的功能，回退链会直接尝试下一个提供方
+var ErrUnsupported = errors.New("该AI服务提供方不支持此功能")
+
+// ErrOutputTruncated 模型输出达到 max_tokens 上限被截断（finish_reason 为 length），不能当作完整结果返回
+var ErrOutputTruncated = errors.New("AI输出因长度限制被截断")
+
+// ProviderError AI服务提供方返回的错误，携带是否可重试等分类信息
+type ProviderError struct {
+	Provider   string
+	StatusCode int  // HTTP状态码，网络错误时为 0
+	Retryable  bool // 超时、网络错误、429、5xx 等暂时性错误
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (provider=%s, status=%d)", e.Err.Error(), e.Provider, e.StatusCode)
+	}
+	return fmt.Sprintf("%s (provider=%s)", e.Err.Error(), e.Provider)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError 根据HTTP状态码构造提供方错误
+func newStatusError(provider string, statusCode int, err error) *ProviderError {
+	return &ProviderError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Retryable:  statusCode == http.StatusTooManyRequests || statusCode >= 500,
+		Err:        err,
+	}
+}
+
+// shouldFallback 判断错误是否应切换到下一个提供方：
+// 暂时性错误，以及余额不足、密钥失效等该提供方自身的问题
+func shouldFallback(err error) bool {
+	if errors.Is(err, ErrUnsupported) {
+		return true
+	}
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) {
+		return false
+	}
+	if providerErr.Retryable {
+		return true
+	}
+	switch providerErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FallbackAIService 按顺序尝试多个提供方，出现可切换的错误时使用下一个，
+// 并在冷却期内跳过最近失败的提供方
+type FallbackAIService struct {
+	providers []AIService
+	cooldown  time.Duration
+	timeout   time.Duration // 单次润色请求的截止时间，0 表示不限制
+
+	mutex          sync.Mutex
+	unhealthyUntil map[string]time.Time
+}
+
+// NewFallbackAIService 创建带回退链的AI服务
+func NewFallbackAIService(providers []AIService, cooldown, timeout time.Duration) *FallbackAIService {
+	return &FallbackAIService{
+		providers:      providers,
+		cooldown:       cooldown,
+		timeout:        timeout,
+		unhealthyUntil: make(map[string]time.Time),
+	}
+}
+
+// Name 返回回退链中的提供方名称
+func (s *FallbackAIService) Name() string {
+	names := make([]string, 0, len(s.providers))
+	for _, provider := range s.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// PolishEssay 依次尝试各提供方润色作文
+func (s *FallbackAIService) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	return tryProviders(ctx, s, func(ctx context.Context, provider AIService) (*PolishResult, error) {
+		return provider.PolishEssay(ctx, req)
+	}, nil)
+}
+
+// PolishEssayStream 依次尝试各提供方流式润色作文
+//
+// 一旦已经向客户端发送了增量内容，就不再切换提供方，以免输出重复的内容。
+func (s *FallbackAIService) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	started := false
+	return tryProviders(ctx, s, func(ctx context.Context, provider AIService) (*PolishResult, error) {
+		return provider.PolishEssayStream(ctx, req, func(delta string) error {
+			started = true
+			return onDelta(delta)
+		})
+	}, func() bool { return started })
+}
+
+// ScoreEssay 依次尝试各提供方给作文打分，跳过不支持评分的提供方
+func (s *FallbackAIService) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	return tryProviders(ctx, s, func(ctx context.Context, provider AIService) (*models.EssayScore, error) {
+		return provider.ScoreEssay(ctx, req, rubric)
+	}, nil)
+}
+
+// tryProviders 按健康状态排序后依次调用提供方，started 返回 true 时停止回退
+//
+// 调用方取消请求或超过截止时间时立即返回，不切换提供方，也不影响提供方的健康状态。
+func tryProviders[T any](ctx context.Context, s *FallbackAIService, call func(ctx context.Context, provider AIService) (T, error), started func() bool) (T, error) {
+	var zero T
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for _, provider := range s.orderedProviders() {
+		result, err := call(ctx, provider)
+		if err == nil {
+			s.markHealthy(provider.Name())
+			return result, nil
+		}
+
+		log.Printf("AI服务提供方 %s 调用失败: %v", provider.Name(), err)
+		if ctx.Err() != nil {
+			return zero, fmt.Errorf("AI请求已取消: %w", ctx.Err())
+		}
+		errs = append(errs, err)
+		if !shouldFallback(err) {
+			return zero, err
+		}
+		// 不支持该功能不代表提供方不健康
+		if !errors.Is(err, ErrUnsupported) {
+			s.markUnhealthy(provider.Name())
+		}
+		if started != nil && started() {
+			return zero, err
+		}
+	}
+	if len(errs) == 0 {
+		return zero, errors.New("没有可用的AI服务提供方")
+	}
+	return zero, fmt.Errorf("所有AI服务提供方均失败: %w", errors.Join(errs...))
+}
+
+// orderedProviders 返回本次调用的尝试顺序：健康的提供方按配置顺序在前，冷却中的提供方在后
+func (s *FallbackAIService) orderedProviders() []AIService {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	var healthy, cooling []AIService
+	for _, provider := range s.providers {
+		if until, ok := s.unhealthyUntil[provider.Name()]; ok && now.Before(until) {
+			cooling = append(cooling, provider)
+			continue
+		}
+		healthy = append(healthy, provider)
+	}
+	return append(healthy, cooling...)
+}
+
+// markUnhealthy 将提供方标记为冷却中
+func (s *FallbackAIService) markUnhealthy(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.unhealthyUntil[name] = time.Now().Add(s.cooldown)
+	log.Printf("AI服务提供方 %s 进入冷却期，%v 内优先使用其他提供方", name, s.cooldown)
+}
+
+// markHealthy 清除提供方的冷却状态
+func (s *FallbackAIService) markHealthy(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.unhealthyUntil, name)
+}
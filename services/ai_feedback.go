@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"essay-go/models"
+	"fmt"
+	"strings"
+)
+
+// parseFeedback 解析提供方返回的结构化反馈JSON
+//
+// 兼容模型在JSON外面包裹 ```json 代码块的情况。
+func parseFeedback(raw string) (*models.EssayFeedback, error) {
+	var feedback models.EssayFeedback
+	if err := unmarshalModelJSON(raw, &feedback); err != nil {
+		return nil, fmt.Errorf("解析结构化反馈失败: %w", err)
+	}
+	if strings.TrimSpace(feedback.PolishedContent) == "" {
+		return nil, errors.New("结构化反馈中缺少 polishedContent")
+	}
+	if feedback.Issues == nil {
+		feedback.Issues = []models.EssayIssue{}
+	}
+	if feedback.Strengths == nil {
+		feedback.Strengths = []string{}
+	}
+	return &feedback, nil
+}
+
+// unmarshalModelJSON 解析模型输出的 JSON，模型有时会把 JSON 放在 ``` 代码块中，先去掉代码块标记
+func unmarshalModelJSON(raw string, v any) error {
+	text := strings.TrimSpace(raw)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	return json.Unmarshal([]byte(text), v)
+}
+
+// applyFeedback 对请求了结构化反馈的结果进行解析，解析失败时把原始输出当作润色后的正文
+func applyFeedback(req *PolishRequest, result *PolishResult) *PolishResult {
+	if !req.Structured {
+		return result
+	}
+
+	feedback, err := parseFeedback(result.Content)
+	if err != nil {
+		fmt.Printf("[applyFeedback] %v，按纯文本处理\n", err)
+		return result
+	}
+	result.Content = feedback.PolishedContent
+	result.Feedback = feedback
+	return result
+}
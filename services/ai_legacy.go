@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"essay-go/config"
+	"essay-go/models"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func init() {
+	RegisterProvider("legacy", func(cfg *config.Config) (AIService, error) {
+		if cfg.AIEndpoint == "" || cfg.AIKey == "" {
+			return nil, errors.New("未配置 AI_ENDPOINT 或 AI_KEY")
+		}
+		return &legacyProvider{
+			endpoint: cfg.AIEndpoint,
+			apiKey:   cfg.AIKey,
+			retry:    retryPolicyFromConfig(cfg),
+		}, nil
+	})
+}
+
+// legacyProvider 旧版润色服务，接收 title/content 并返回 polished_content
+type legacyProvider struct {
+	endpoint string
+	apiKey   string
+	retry    RetryPolicy
+}
+
+// Name 返回提供方名称
+func (p *legacyProvider) Name() string {
+	return "legacy"
+}
+
+// PolishEssay 使用旧版AI服务润色作文
+func (p *legacyProvider) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	// 旧版服务只接受单篇作文，不支持多轮对话
+	if len(req.History) > 0 {
+		return nil, ErrUnsupported
+	}
+
+	fmt.Printf("[legacyProvider] 使用旧版AI服务润色, 端点: %s, 模式: %s\n", p.endpoint, req.Mode)
+
+	// 准备请求数据
+	requestData := map[string]interface{}{
+		"title":     req.Title,
+		"content":   req.Content,
+		"mode":      req.Mode,
+		"wordLimit": req.WordLimit,
+	}
+
+	jsonData, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+
+	// 创建HTTP请求
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	// 设置请求头
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	// 发送请求
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := doWithRetry(client, httpReq, p.retry)
+	if err != nil {
+		return nil, &ProviderError{Provider: "legacy", Retryable: true, Err: fmt.Errorf("发送AI请求失败: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("legacy", resp.StatusCode, fmt.Errorf("AI服务返回错误状态码: %d", resp.StatusCode))
+	}
+
+	// 解析响应
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+
+	// 获取润色后的内容
+	polishedContent, ok := result["polished_content"].(string)
+	if !ok {
+		return nil, errors.New("AI响应中未找到润色内容")
+	}
+
+	return &PolishResult{Content: polishedContent, Provider: "legacy"}, nil
+}
+
+// PolishEssayStream 旧版服务不支持流式输出，一次性返回
+func (p *legacyProvider) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	return polishOnce(ctx, p, req, onDelta)
+}
+
+// ScoreEssay 旧版服务不支持评分
+func (p *legacyProvider) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	return nil, ErrUnsupported
+}
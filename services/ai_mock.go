@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"essay-go/config"
+	"essay-go/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func init() {
+	RegisterProvider("mock", func(cfg *config.Config) (AIService, error) {
+		return &mockProvider{}, nil
+	})
+}
+
+// mockProvider 模拟润色（未配置任何AI服务时使用）
+type mockProvider struct{}
+
+// Name 返回提供方名称
+func (p *mockProvider) Name() string {
+	return "mock"
+}
+
+// PolishEssay 模拟润色作文
+func (p *mockProvider) PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error) {
+	// 模拟服务无法理解修改要求，交互式润色时原样返回上一次的结果
+	if len(req.History) > 0 {
+		return &PolishResult{Content: lastAssistantMessage(req.History, req.Content), Provider: "mock"}, nil
+	}
+
+	// 简单的模拟润色逻辑
+	polished := req.Content
+	issues := []models.EssayIssue{}
+
+	// 1. 修正标点符号
+	polished = strings.ReplaceAll(polished, "，", "，")
+	polished = strings.ReplaceAll(polished, "。", "。")
+	polished = strings.ReplaceAll(polished, "？", "？")
+	polished = strings.ReplaceAll(polished, "！", "！")
+
+	switch req.Mode {
+	case models.PolishModeProofread:
+		// 只校对，不改动用词
+
+	case models.PolishModeCondense:
+		// 直接截断到目标字数
+		if runes := []rune(polished); len(runes) > condenseLimit(req) {
+			polished = string(runes[:condenseLimit(req)])
+		}
+
+	default:
+		// 2. 替换一些润色词汇，并记录为修改建议
+		for _, replacement := range mockReplacements {
+			if strings.Contains(polished, replacement.Original) {
+				polished = strings.ReplaceAll(polished, replacement.Original, replacement.Suggestion)
+				issues = append(issues, replacement)
+			}
+		}
+
+		if req.Mode == models.PolishModeExpand {
+			polished = polished + "\n\n那一刻的情景，至今仍清晰地浮现在我的眼前。"
+		}
+	}
+
+	// 3. 评语放在结构化反馈中，不再混入正文
+	return &PolishResult{
+		Content:  polished,
+		Provider: "mock",
+		Feedback: &models.EssayFeedback{
+			PolishedContent: polished,
+			Issues:          issues,
+			Strengths:       []string{"结构清晰", "内容生动"},
+			Comment:         "这篇作文结构清晰，内容生动。可以适当增加一些细节描写，让文章更加丰富多彩。",
+		},
+	}, nil
+}
+
+// mockReplacements 模拟润色使用的词语替换
+var mockReplacements = []models.EssayIssue{
+	{Type: "wording", Original: "很好", Suggestion: "非常棒", Explanation: "“非常棒”语气更强烈，更能表达喜爱之情。"},
+	{Type: "wording", Original: "看到", Suggestion: "目睹", Explanation: "“目睹”表示亲眼看见，更加生动。"},
+	{Type: "wording", Original: "说", Suggestion: "表达", Explanation: "换一个词可以避免重复。"},
+}
+
+// PolishEssayStream 模拟润色不支持流式输出，一次性返回
+func (p *mockProvider) PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	return polishOnce(ctx, p, req, onDelta)
+}
+
+// ScoreEssay 模拟评分：每项按满分的七到九成给分，分数随正文长度略有变化
+func (p *mockProvider) ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error) {
+	length := utf8.RuneCountInString(req.Content)
+	ratio := 0.7 + float64(length%20)/100
+
+	score := &models.EssayScore{
+		Comment:  "这是模拟评分结果，配置AI服务后可以获得真实的评分。",
+		Rubric:   rubric.Name,
+		Provider: "mock",
+		ScoredAt: time.Now().Format(time.RFC3339),
+	}
+	for _, criterion := range rubric.Criteria {
+		points := int(float64(criterion.MaxScore)*ratio + 0.5)
+		score.Criteria = append(score.Criteria, models.CriterionScore{
+			Key:           criterion.Key,
+			Name:          criterion.Name,
+			Score:         points,
+			MaxScore:      criterion.MaxScore,
+			Justification: "模拟评分",
+		})
+		score.Total += points
+		score.MaxTotal += criterion.MaxScore
+	}
+	return score, nil
+}
+
+// lastAssistantMessage 返回对话中最后一条助手回复，没有时返回 fallback
+func lastAssistantMessage(history []ChatMessage, fallback string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" {
+			return history[i].Content
+		}
+	}
+	return fallback
+}
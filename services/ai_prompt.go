@@ -0,0 +1,447 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// 提示词模板文件中未声明生成参数时使用的默认值
+const (
+	defaultPromptTemperature = 0.7
+	defaultPromptMaxTokens   = 2000
+)
+
+// gradeBands 各学段的语言要求，按年级上限升序排列
+var gradeBands = []struct {
+	maxGrade int
+	guidance string
+}{
+	{2, "多用短句和常用字词，句子结构简单，不使用成语和复杂修辞，可以保留儿童化的表达。"},
+	{4, "以简单句为主，可以适当使用常见的比喻、拟人和少量常用成语，避免生僻词和长难句。"},
+	{6, "可以使用常见的修辞手法和成语，句式可以有一定变化，但不要堆砌辞藻，避免书面化、成人化的表达。"},
+	{9, "可以使用较丰富的词汇和多样的句式，适当运用描写和议论，但要符合初中生的认知和生活经验。"},
+	{12, "可以使用较成熟的书面语和论证结构，语言准确凝练，但仍要保持学生的视角，避免空泛的套话。"},
+}
+
+// gradeName 返回年级的中文名称，例如 3 → 小学三年级，8 → 初中二年级
+func gradeName(grade int) string {
+	names := []string{"一", "二", "三", "四", "五", "六"}
+	switch {
+	case grade <= 6:
+		return "小学" + names[grade-1] + "年级"
+	case grade <= 9:
+		return "初中" + names[grade-7] + "年级"
+	default:
+		return "高中" + names[grade-10] + "年级"
+	}
+}
+
+// gradeGuidance 返回年级对应的语言要求
+func gradeGuidance(grade int) string {
+	for _, band := range gradeBands {
+		if grade <= band.maxGrade {
+			return band.guidance
+		}
+	}
+	return ""
+}
+
+// condenseLimit 返回缩写的目标字数，未指定时取原文的七成
+func condenseLimit(req *PolishRequest) int {
+	if req.WordLimit > 0 {
+		return req.WordLimit
+	}
+	return utf8.RuneCountInString(req.Content) * 7 / 10
+}
+
+// promptData 渲染提示词模板时可以使用的字段
+type promptData struct {
+	Title         string
+	Content       string
+	Mode          string
+	WordLimit     int            // condense 模式的目标字数（已补全默认值）
+	GradeLevel    int            // 0 表示未指定
+	GradeName     string         // 例如 "小学三年级"
+	GradeGuidance string         // 该学段的语言要求
+	Structured    bool           // 是否要求返回JSON格式的结构化反馈
+	ContextBefore string         // 分段润色时的前文（已润色），为空表示不分段或第一段
+	ContextAfter  string         // 分段润色时的后文（原文）
+	Rubric        *models.Rubric // 评分标准，仅评分模板使用
+	Instruction   string         // 用户的修改要求，仅追问模板使用
+}
+
+// newPromptData 根据润色参数构造模板数据
+func newPromptData(req *PolishRequest) promptData {
+	data := promptData{
+		Title:         req.Title,
+		Content:       req.Content,
+		Mode:          req.Mode,
+		WordLimit:     condenseLimit(req),
+		Structured:    req.Structured,
+		ContextBefore: req.ContextBefore,
+		ContextAfter:  req.ContextAfter,
+	}
+	if models.IsValidGradeLevel(req.GradeLevel) {
+		data.GradeLevel = req.GradeLevel
+		data.GradeName = gradeName(req.GradeLevel)
+		data.GradeGuidance = gradeGuidance(req.GradeLevel)
+	}
+	return data
+}
+
+// RenderedPrompt 渲染后的提示词及模板声明的生成参数
+type RenderedPrompt struct {
+	Text        string
+	Temperature float64
+	MaxTokens   int
+	Version     string // 模板内容的哈希，模板修改后随之变化
+
+	models map[string]string // 模板声明的模型，键为提供方名称，"" 表示适用于所有提供方
+}
+
+// ModelFor 返回该提示词在指定提供方上使用的模型，模板未声明时返回 defaultModel
+func (p *RenderedPrompt) ModelFor(provider, defaultModel string) string {
+	if model := p.models[provider]; model != "" {
+		return model
+	}
+	if model := p.models[""]; model != "" {
+		return model
+	}
+	return defaultModel
+}
+
+// scoreTemplateName 评分提示词模板的名称
+const scoreTemplateName = "score"
+
+// followUpTemplateName 交互式润色中用户追问修改要求时的提示词模板名称
+const followUpTemplateName = "followup"
+
+// promptTemplate 一个润色模式的提示词模板
+type promptTemplate struct {
+	tmpl        *template.Template
+	temperature float64
+	maxTokens   int
+	models      map[string]string
+	version     string
+}
+
+// PromptStore 从目录加载提示词模板，并在文件变化时自动重新加载
+//
+// 目录中每个 <模式>.tmpl 文件对应一个润色模式，score.tmpl 用于评分，followup.tmpl 用于交互式润色的追问；
+// 以 "_" 开头的文件是公共片段，其中用 {{define}} 定义的模板可以被所有模式引用。
+// 模式模板的开头可以用 "key: value" 的形式声明 model、model.<提供方>、temperature、max_tokens，
+// 与正文之间以 "---" 行分隔。
+type PromptStore struct {
+	dir string
+
+	mutex     sync.RWMutex
+	templates map[string]*promptTemplate
+	modTimes  map[string]time.Time
+}
+
+// 全局提示词模板实例
+var promptStore *PromptStore
+
+// InitPromptStore 加载并校验提示词模板，interval 大于 0 时定期检查文件变化并重新加载
+func InitPromptStore(dir string, interval time.Duration) error {
+	store := &PromptStore{dir: dir}
+	if err := store.reload(); err != nil {
+		return err
+	}
+	promptStore = store
+
+	if interval > 0 {
+		go store.watch(interval)
+	}
+	return nil
+}
+
+// GetPromptStore 返回全局提示词模板实例
+func GetPromptStore() *PromptStore {
+	return promptStore
+}
+
+// Render 渲染润色参数对应模式的提示词
+func (s *PromptStore) Render(req *PolishRequest) (*RenderedPrompt, error) {
+	return s.render(req.Mode, newPromptData(req))
+}
+
+// RenderScore 按评分标准渲染评分提示词（score.tmpl）
+func (s *PromptStore) RenderScore(req *PolishRequest, rubric *models.Rubric) (*RenderedPrompt, error) {
+	data := newPromptData(req)
+	data.Rubric = rubric
+	return s.render(scoreTemplateName, data)
+}
+
+// RenderFollowUp 渲染交互式润色中用户提出修改要求的消息（followup.tmpl），
+// 生成参数沿用首次润色的模式模板，这里只使用渲染后的文本
+func (s *PromptStore) RenderFollowUp(req *PolishRequest, instruction string) (string, error) {
+	data := newPromptData(req)
+	data.Instruction = instruction
+	prompt, err := s.render(followUpTemplateName, data)
+	if err != nil {
+		return "", err
+	}
+	return prompt.Text, nil
+}
+
+// render 渲染指定名称的模板
+func (s *PromptStore) render(name string, data promptData) (*RenderedPrompt, error) {
+	s.mutex.RLock()
+	pt, exists := s.templates[name]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("未找到提示词模板 %s", name)
+	}
+
+	var buf bytes.Buffer
+	if err := pt.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("渲染提示词模板 %s 失败: %w", name, err)
+	}
+
+	return &RenderedPrompt{
+		Text:        strings.TrimSpace(buf.String()),
+		Temperature: pt.temperature,
+		MaxTokens:   pt.maxTokens,
+		Version:     pt.version,
+		models:      pt.models,
+	}, nil
+}
+
+// watch 定期检查模板文件的修改时间，有变化时重新加载
+func (s *PromptStore) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		modTimes, err := scanPromptFiles(s.dir)
+		if err != nil {
+			log.Printf("检查提示词模板目录失败: %v", err)
+			continue
+		}
+
+		s.mutex.RLock()
+		changed := !sameModTimes(modTimes, s.modTimes)
+		s.mutex.RUnlock()
+		if !changed {
+			continue
+		}
+
+		// 新模板校验失败时继续使用旧模板
+		if err := s.reload(); err != nil {
+			log.Printf("重新加载提示词模板失败，继续使用旧模板: %v", err)
+			continue
+		}
+		log.Printf("提示词模板已重新加载")
+	}
+}
+
+// reload 重新加载并校验目录中的全部模板，校验通过后整体替换
+func (s *PromptStore) reload() error {
+	modTimes, err := scanPromptFiles(s.dir)
+	if err != nil {
+		return err
+	}
+
+	// 先解析所有公共片段
+	var partialNames, modeNames []string
+	for path := range modTimes {
+		if strings.HasPrefix(filepath.Base(path), "_") {
+			partialNames = append(partialNames, path)
+		} else {
+			modeNames = append(modeNames, path)
+		}
+	}
+	sort.Strings(partialNames)
+
+	base := template.New("_base")
+	partialHash := sha256.New()
+	for _, path := range partialNames {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取提示词片段 %s 失败: %w", path, err)
+		}
+		if _, err := base.New(filepath.Base(path)).Parse(string(content)); err != nil {
+			return fmt.Errorf("解析提示词片段 %s 失败: %w", path, err)
+		}
+		partialHash.Write(content)
+	}
+
+	templates := make(map[string]*promptTemplate)
+	for _, path := range modeNames {
+		mode := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		pt, err := loadPromptTemplate(base, path, partialHash.Sum(nil))
+		if err != nil {
+			return err
+		}
+		templates[mode] = pt
+	}
+
+	// 每个润色模式、评分和追问都必须有模板，并且能用示例数据成功渲染
+	for _, name := range []string{models.PolishModeProofread, models.PolishModePolish, models.PolishModeExpand, models.PolishModeCondense, scoreTemplateName, followUpTemplateName} {
+		pt, exists := templates[name]
+		if !exists {
+			return fmt.Errorf("提示词目录 %s 中缺少模板 %s.tmpl", s.dir, name)
+		}
+		for _, grade := range []int{0, 3} {
+			for _, structured := range []bool{false, true} {
+				sample := NewPolishRequest(models.EssayRequest{Title: "示例标题", Content: "示例正文。", Mode: name, GradeLevel: grade})
+				sample.Structured = structured
+				if grade != 0 {
+					sample.ContextBefore, sample.ContextAfter = "示例前文。", "示例后文。"
+				}
+				data := newPromptData(sample)
+				data.Rubric = DefaultRubric()
+				data.Instruction = "示例修改要求"
+				var buf bytes.Buffer
+				if err := pt.tmpl.Execute(&buf, data); err != nil {
+					return fmt.Errorf("校验提示词模板 %s 失败: %w", name, err)
+				}
+			}
+		}
+	}
+
+	s.mutex.Lock()
+	s.templates = templates
+	s.modTimes = modTimes
+	s.mutex.Unlock()
+
+	log.Printf("已加载 %d 个提示词模板（目录: %s）", len(templates), s.dir)
+	return nil
+}
+
+// loadPromptTemplate 解析一个模式模板文件：头部的生成参数和 "---" 之后的模板正文
+func loadPromptTemplate(base *template.Template, path string, partialHash []byte) (*promptTemplate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取提示词模板 %s 失败: %w", path, err)
+	}
+
+	pt := &promptTemplate{
+		temperature: defaultPromptTemperature,
+		maxTokens:   defaultPromptMaxTokens,
+		models:      make(map[string]string),
+	}
+
+	header, body, hasHeader := splitPromptHeader(string(content))
+	if hasHeader {
+		if err := pt.parseHeader(header); err != nil {
+			return nil, fmt.Errorf("提示词模板 %s 头部无效: %w", path, err)
+		}
+	}
+
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if pt.tmpl, err = tmpl.New(filepath.Base(path)).Parse(body); err != nil {
+		return nil, fmt.Errorf("解析提示词模板 %s 失败: %w", path, err)
+	}
+
+	hash := sha256.New()
+	hash.Write(partialHash)
+	hash.Write(content)
+	pt.version = hex.EncodeToString(hash.Sum(nil))[:12]
+	return pt, nil
+}
+
+// splitPromptHeader 以第一行 "---" 分隔头部和正文，没有分隔行时整个文件都是正文
+func splitPromptHeader(content string) (header, body string, ok bool) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if strings.HasPrefix(content, "---\n") {
+		return "", content[len("---\n"):], true
+	}
+	if index := strings.Index(content, "\n---\n"); index >= 0 {
+		return content[:index], content[index+len("\n---\n"):], true
+	}
+	return "", content, false
+}
+
+// parseHeader 解析 "key: value" 形式的生成参数
+func (pt *promptTemplate) parseHeader(header string) error {
+	scanner := bufio.NewScanner(strings.NewReader(header))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("无法解析的行: %s", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch {
+		case key == "model":
+			pt.models[""] = value
+		case strings.HasPrefix(key, "model."):
+			pt.models[strings.TrimPrefix(key, "model.")] = value
+		case key == "temperature":
+			temperature, err := strconv.ParseFloat(value, 64)
+			if err != nil || temperature < 0 || temperature > 2 {
+				return fmt.Errorf("temperature 必须是 0 到 2 之间的数字: %s", value)
+			}
+			pt.temperature = temperature
+		case key == "max_tokens":
+			maxTokens, err := strconv.Atoi(value)
+			if err != nil || maxTokens <= 0 {
+				return fmt.Errorf("max_tokens 必须是正整数: %s", value)
+			}
+			pt.maxTokens = maxTokens
+		default:
+			return fmt.Errorf("未知的参数: %s", key)
+		}
+	}
+	return scanner.Err()
+}
+
+// scanPromptFiles 返回目录中所有 .tmpl 文件及其修改时间
+func scanPromptFiles(dir string) (map[string]time.Time, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("提示词目录 " + dir + " 中没有 .tmpl 文件")
+	}
+
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// sameModTimes 判断两次扫描的文件集合和修改时间是否一致
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, modTime := range a {
+		if other, exists := b[path]; !exists || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"essay-go/config"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProviderFactory 根据配置创建一个AI服务提供方，缺少必要配置时返回错误
+type ProviderFactory func(cfg *config.Config) (AIService, error)
+
+var (
+	providerMutex    sync.RWMutex
+	providerRegistry = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider 注册AI服务提供方，通常在各提供方文件的 init 中调用
+func RegisterProvider(name string, factory ProviderFactory) {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, exists := providerRegistry[name]; exists {
+		panic(fmt.Sprintf("AI服务提供方 %s 重复注册", name))
+	}
+	providerRegistry[name] = factory
+}
+
+// NewProvider 按名称创建AI服务提供方
+func NewProvider(name string, cfg *config.Config) (AIService, error) {
+	providerMutex.RLock()
+	factory, exists := providerRegistry[strings.ToLower(strings.TrimSpace(name))]
+	providerMutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("未知的AI服务提供方: %s (可选: %s)", name, strings.Join(ProviderNames(), ", "))
+	}
+	return factory(cfg)
+}
+
+// ProviderNames 返回已注册的提供方名称
+func ProviderNames() []string {
+	providerMutex.RLock()
+	defer providerMutex.RUnlock()
+
+	names := make([]string, 0, len(providerRegistry))
+	for name := range providerRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// autoProviderOrder AI_PROVIDER=auto 时组成回退链的提供方顺序，未配置完整的会被跳过
+var autoProviderOrder = []string{"deepseek", "openai", "legacy"}
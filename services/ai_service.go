@@ -1,254 +1,125 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
+	"context"
 	"essay-go/config"
+	"essay-go/models"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
 	"strings"
-	"time"
 )
 
-// AIService AI服务接口
+// AIService AI服务接口，每个AI服务提供方（DeepSeek、OpenAI兼容服务、旧版服务、模拟润色）都是一个实现
 type AIService interface {
-	PolishEssay(title, content string) (string, error)
+	// Name 返回提供方名称
+	Name() string
+	PolishEssay(ctx context.Context, req *PolishRequest) (*PolishResult, error)
+	// PolishEssayStream 流式润色作文，每收到一段增量文本就调用 onDelta，返回完整的润色结果
+	PolishEssayStream(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error)
+	// ScoreEssay 按评分标准给作文打分，不支持评分的提供方返回 ErrUnsupported
+	ScoreEssay(ctx context.Context, req *PolishRequest, rubric *models.Rubric) (*models.EssayScore, error)
 }
 
-// DefaultAIService 默认AI服务实现
-type DefaultAIService struct {
-	cfg *config.Config
+// PolishRequest 服务层的润色参数
+type PolishRequest struct {
+	models.EssayRequest
+	// Structured 为 true 时要求提供方返回JSON格式的结构化反馈，流式输出时不使用
+	Structured bool
+	// Username 发起请求的用户，token用量记在该用户名下，未登录时为空
+	Username string
+	// 分段润色长作文时，相邻段落的内容，只用于保持衔接，不需要润色
+	ContextBefore string
+	ContextAfter  string
+	// History 交互式润色中首次润色提示词之后的对话（助手的回复和用户的修改要求交替出现），
+	// 以用户的修改要求结尾；为空表示普通的单次润色
+	History []ChatMessage
 }
 
-// NewAIService 创建新的AI服务
-func NewAIService(cfg *config.Config) AIService {
-	return &DefaultAIService{
-		cfg: cfg,
-	}
+// ChatMessage 对话中的一条消息
+type ChatMessage struct {
+	Role    string `json:"role"` // user 或 assistant
+	Content string `json:"content"`
 }
 
-// PolishEssay 使用AI润色作文
-func (s *DefaultAIService) PolishEssay(title, content string) (string, error) {
-	fmt.Printf("[PolishEssay] 开始润色作文, 标题: %s, 内容长度: %d字符\n", title, len(content))
-	
-	// 如果配置了DeepSeek API密钥，使用DeepSeek润色
-	if s.cfg.DeepSeekAPIKey != "" {
-		fmt.Printf("[PolishEssay] 使用DeepSeek API润色, 密钥长度: %d\n", len(s.cfg.DeepSeekAPIKey))
-		return s.deepSeekPolish(title, content)
-	}
-
-	// 如果配置了其他AI服务，使用其他AI服务
-	if s.cfg.AIEndpoint != "" && s.cfg.AIKey != "" {
-		fmt.Printf("[PolishEssay] 使用其他AI服务润色, 端点: %s\n", s.cfg.AIEndpoint)
-		return s.otherAIPolish(title, content)
+// NewPolishRequest 根据请求体构造润色参数，未指定润色模式时使用 polish
+func NewPolishRequest(essay models.EssayRequest) *PolishRequest {
+	if essay.Mode == "" {
+		essay.Mode = models.PolishModePolish
 	}
-
-	// 如果没有配置AI服务，使用模拟润色
-	fmt.Println("[PolishEssay] 未配置AI服务，使用模拟润色")
-	return s.mockPolish(title, content), nil
+	return &PolishRequest{EssayRequest: essay}
 }
 
-// deepSeekPolish 使用DeepSeek API润色作文
-func (s *DefaultAIService) deepSeekPolish(title, content string) (string, error) {
-	// DeepSeek API端点
-	apiEndpoint := "https://api.deepseek.com/v1/chat/completions"
-	fmt.Printf("[deepSeekPolish] 开始调用DeepSeek API, 端点: %s\n", apiEndpoint)
-
-	// 准备提示词
-	prompt := fmt.Sprintf("你是一位专业的中文作文润色专家，尤其擅长帮助小学生改进作文。\n\n"+
-		"请帮我润色以下作文，使其更加生动、有表现力、结构合理。保持原文的主要意思和结构，但可以改进语言表达、修正语法错误、丰富词汇和优化段落结构。\n\n"+
-		"作文标题：%s\n\n"+
-		"作文正文：\n%s\n\n"+
-		"请直接返回润色后的完整作文，不需要其他解释。", title, content)
-
-	// 检查DeepSeek模型配置
-	if s.cfg.DeepSeekModel == "" {
-		s.cfg.DeepSeekModel = "deepseek-chat"
-		fmt.Printf("[deepSeekPolish] 未指定模型，使用默认模型: %s\n", s.cfg.DeepSeekModel)
-	} else {
-		fmt.Printf("[deepSeekPolish] 使用模型: %s\n", s.cfg.DeepSeekModel)
-	}
-
-	// 准备请求数据
-	requestData := map[string]interface{}{
-		"model": s.cfg.DeepSeekModel,
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"temperature": 0.7,
-		"max_tokens":  2000,
-	}
-
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		fmt.Printf("[deepSeekPolish] 序列化请求数据失败: %v\n", err)
-		return "", fmt.Errorf("序列化请求数据失败: %w", err)
-	}
+// PolishResult 润色结果
+type PolishResult struct {
+	Content  string
+	Provider string                // 实际完成润色的提供方
+	Feedback *models.EssayFeedback // 结构化反馈，未请求或解析失败时为 nil
+	Usage    models.TokenUsage     // 本次润色消耗的token，不统计用量的提供方为 0
+	Cached   bool                  // 是否来自结果缓存
+}
 
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("[deepSeekPolish] 创建HTTP请求失败: %v\n", err)
-		return "", fmt.Errorf("创建HTTP请求失败: %w", err)
-	}
+// 全局AI服务实例，回退链的健康状态需要在请求之间共享
+var aiService AIService
 
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.cfg.DeepSeekAPIKey)
-	fmt.Println("[deepSeekPolish] 请求头设置完成，准备发送请求")
+// InitAIService 根据配置初始化全局AI服务
+func InitAIService(cfg *config.Config) {
+	aiService = NewAIService(cfg)
+}
 
-	// 发送请求
-	client := &http.Client{Timeout: 60 * time.Second}
-	fmt.Println("[deepSeekPolish] 开始发送HTTP请求...")
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("[deepSeekPolish] 发送DeepSeek请求失败: %v\n", err)
-		return "", fmt.Errorf("发送DeepSeek请求失败: %w", err)
-	}
-	defer resp.Body.Close()
+// GetAIService 返回全局AI服务实例
+func GetAIService() AIService {
+	return aiService
+}
 
-	// 检查响应状态
-	fmt.Printf("[deepSeekPolish] 收到响应，状态码: %d\n", resp.StatusCode)
-	if resp.StatusCode != http.StatusOK {
-		// 读取错误响应体
-		var errorResponse map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err == nil {
-			fmt.Printf("[deepSeekPolish] DeepSeek API错误响应: %v\n", errorResponse)
-			return "", fmt.Errorf("DeepSeek API错误: %v", errorResponse)
+// NewAIService 根据 AI_PROVIDER 配置创建AI服务
+//
+// AI_PROVIDER 是逗号分隔的提供方列表，按顺序组成回退链，例如 "deepseek,openai,legacy"。
+// 为 auto（默认）时按 deepseek、openai、legacy 的顺序使用所有配置完整的提供方，
+// 都未配置时使用模拟润色。
+func NewAIService(cfg *config.Config) AIService {
+	var providers []AIService
+	if cfg.AIProvider == "" || cfg.AIProvider == "auto" {
+		for _, name := range autoProviderOrder {
+			provider, err := NewProvider(name, cfg)
+			if err != nil {
+				continue
+			}
+			providers = append(providers, provider)
+		}
+	} else {
+		for _, name := range strings.Split(cfg.AIProvider, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			provider, err := NewProvider(name, cfg)
+			if err != nil {
+				log.Printf("创建AI服务提供方 %s 失败: %v", name, err)
+				continue
+			}
+			providers = append(providers, provider)
 		}
-		fmt.Printf("[deepSeekPolish] DeepSeek API返回错误状态码: %d\n", resp.StatusCode)
-		return "", fmt.Errorf("DeepSeek API返回错误状态码: %d", resp.StatusCode)
-	}
-
-    // 读取响应体
-    respBody, err := io.ReadAll(resp.Body)
-    if err != nil {
-        fmt.Printf("[deepSeekPolish] 读取DeepSeek响应体失败: %v\n", err)
-        return "", fmt.Errorf("读取DeepSeek响应体失败: %w", err)
-    }
-    
-    // 打印响应体（截断版本以防止日志过长）
-    const maxLogResponseBodyLength = 1024 // 允许记录的最大响应体长度
-    responseBodyStr := string(respBody)
-    if len(responseBodyStr) > maxLogResponseBodyLength {
-        fmt.Printf("[deepSeekPolish] DeepSeek API响应 (truncated to %d chars): %s...\n", maxLogResponseBodyLength, responseBodyStr[:maxLogResponseBodyLength])
-    } else {
-        fmt.Printf("[deepSeekPolish] DeepSeek API响应: %s\n", responseBodyStr)
-    }
-    
-    // 解析响应
-    fmt.Println("[deepSeekPolish] 开始解析响应...")
-    var result map[string]interface{}
-    if err := json.Unmarshal(respBody, &result); err != nil {
-        fmt.Printf("[deepSeekPolish] 解析DeepSeek响应失败: %v\n", err)
-        return "", fmt.Errorf("解析DeepSeek响应失败: %w", err)
-    }
-
-	// 获取润色后的内容
-	choices, ok := result["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		fmt.Println("[deepSeekPolish] DeepSeek响应中未找到有效的choices")
-		return "", errors.New("DeepSeek响应中未找到有效的choices")
-	}
-
-	firstChoice, ok := choices[0].(map[string]interface{})
-	if !ok {
-		fmt.Println("[deepSeekPolish] DeepSeek响应中的choice格式无效")
-		return "", errors.New("DeepSeek响应中的choice格式无效")
-	}
-
-	message, ok := firstChoice["message"].(map[string]interface{})
-	if !ok {
-		fmt.Println("[deepSeekPolish] DeepSeek响应中的message格式无效")
-		return "", errors.New("DeepSeek响应中的message格式无效")
 	}
 
-	polishedContent, ok := message["content"].(string)
-	if !ok {
-		fmt.Println("[deepSeekPolish] DeepSeek响应中未找到润色内容")
-		return "", errors.New("DeepSeek响应中未找到润色内容")
+	if len(providers) == 0 {
+		log.Println("警告: 没有可用的AI服务提供方，使用模拟润色")
+		providers = append(providers, &mockProvider{})
 	}
 
-	fmt.Printf("[deepSeekPolish] 润色成功，润色后内容长度: %d字符\n", len(polishedContent))
-	return polishedContent, nil
+	service := NewFallbackAIService(providers, cfg.AIProviderCooldown, cfg.AIRequestTimeout)
+	fmt.Printf("[NewAIService] AI服务提供方回退链: %s\n", service.Name())
+	chunked := NewChunkedAIService(service, cfg.AIChunkSize, cfg.AIChunkContext, cfg.AIChunkTimeout)
+	providerModels := map[string]string{"deepseek": cfg.DeepSeekModel, "openai": cfg.OpenAIModel, "legacy": cfg.AIEndpoint}
+	return NewCachedAIService(chunked, providerModels, cfg.AICacheSize, cfg.AICacheTTL)
 }
 
-// otherAIPolish 使用其他AI服务润色作文
-func (s *DefaultAIService) otherAIPolish(title, content string) (string, error) {
-	// 准备请求数据
-	requestData := map[string]interface{}{
-		"title":   title,
-		"content": content,
-	}
-
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return "", fmt.Errorf("序列化请求数据失败: %w", err)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", s.cfg.AIEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("创建HTTP请求失败: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	if s.cfg.AIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+s.cfg.AIKey)
-	}
-
-	// 发送请求
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// polishOnce 供不支持流式输出的提供方使用：等待完整结果后一次性回调 onDelta
+func polishOnce(ctx context.Context, s AIService, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	result, err := s.PolishEssay(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("发送AI请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("AI服务返回错误状态码: %d", resp.StatusCode)
+		return nil, err
 	}
-
-	// 解析响应
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("解析AI响应失败: %w", err)
+	if err := onDelta(result.Content); err != nil {
+		return nil, err
 	}
-
-	// 获取润色后的内容
-	polishedContent, ok := result["polished_content"].(string)
-	if !ok {
-		return "", errors.New("AI响应中未找到润色内容")
-	}
-
-	return polishedContent, nil
-}
-
-// mockPolish 模拟润色功能（当未配置AI服务时使用）
-func (s *DefaultAIService) mockPolish(title, content string) string {
-	// 简单的模拟润色逻辑
-	polished := content
-
-	// 1. 修正标点符号
-	polished = strings.ReplaceAll(polished, "，", "，")
-	polished = strings.ReplaceAll(polished, "。", "。")
-	polished = strings.ReplaceAll(polished, "？", "？")
-	polished = strings.ReplaceAll(polished, "！", "！")
-
-	// 2. 添加一些润色词汇
-	polished = strings.ReplaceAll(polished, "很好", "非常棒")
-	polished = strings.ReplaceAll(polished, "看到", "目睹")
-	polished = strings.ReplaceAll(polished, "说", "表达")
-
-	// 3. 添加结尾评语
-	polished = polished + "\n\n【AI点评】这篇作文结构清晰，内容生动。可以适当增加一些细节描写，让文章更加丰富多彩。"
-
-	return polished
+	return result, nil
 }
@@ -2,7 +2,9 @@ package services
 
 import (
 	"bufio"
+	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -12,10 +14,17 @@ import (
 // AuthService 提供认证相关功能
 type AuthService struct {
 	users     map[string]string // 用户名 -> 密码
+	profiles  map[string]userProfile
 	authFile  string
 	userMutex sync.RWMutex
 }
 
+// userProfile 用户资料，来自认证文件中密码之后的 key=value 字段
+type userProfile struct {
+	GradeLevel int    // 年级（1-12），0 表示未设置
+	Role       string // 角色，为空表示 user
+}
+
 // 全局认证服务实例
 var authService *AuthService
 var authOnce sync.Once
@@ -25,6 +34,7 @@ func GetAuthService() *AuthService {
 	authOnce.Do(func() {
 		authService = &AuthService{
 			users:    make(map[string]string),
+			profiles: make(map[string]userProfile),
 			authFile: "data/auth.txt",
 		}
 		authService.loadUsers()
@@ -33,6 +43,8 @@ func GetAuthService() *AuthService {
 }
 
 // loadUsers 从文件加载用户信息
+//
+// 每行格式为 "用户名 密码 [key=value ...]"，目前支持的资料字段: grade=年级、role=角色
 func (a *AuthService) loadUsers() error {
 	a.userMutex.Lock()
 	defer a.userMutex.Unlock()
@@ -51,6 +63,7 @@ func (a *AuthService) loadUsers() error {
 			username := parts[0]
 			password := parts[1]
 			a.users[username] = password
+			a.profiles[username] = parseUserProfile(username, parts[2:])
 		}
 	}
 
@@ -80,8 +93,50 @@ func (a *AuthService) GetUser(username string) *models.User {
 		return nil
 	}
 
+	profile := a.profiles[username]
+	role := profile.Role
+	if role == "" {
+		role = models.RoleUser
+	}
 	return &models.User{
-		Username: username,
-		LoggedIn: true,
+		Username:   username,
+		LoggedIn:   true,
+		GradeLevel: profile.GradeLevel,
+		Role:       role,
+	}
+}
+
+// RoleOf 返回用户的角色，未登录或用户不存在时为 anonymous
+func (a *AuthService) RoleOf(username string) string {
+	if username == "" {
+		return models.RoleAnonymous
+	}
+	user := a.GetUser(username)
+	if user == nil {
+		return models.RoleAnonymous
+	}
+	return user.Role
+}
+
+// parseUserProfile 解析认证文件中的资料字段，无法识别的字段会被忽略
+func parseUserProfile(username string, fields []string) userProfile {
+	var profile userProfile
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "grade":
+			grade, err := strconv.Atoi(value)
+			if err != nil || !models.IsValidGradeLevel(grade) {
+				log.Printf("用户 %s 的年级设置无效: %s", username, value)
+				continue
+			}
+			profile.GradeLevel = grade
+		case "role":
+			profile.Role = value
+		}
 	}
+	return profile
 }
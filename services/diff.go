@@ -0,0 +1,282 @@
+package services
+
+import (
+	"essay-go/models"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDiffEdits Myers 算法允许的最大编辑距离，计算时间与文本长度和编辑距离的乘积成正比，
+// 超过后把两段文本整体视为一处替换
+const maxDiffEdits = 4000
+
+// diffToken 分词结果，start 为在原文中的字符偏移
+type diffToken struct {
+	text  string
+	start int
+}
+
+// diffOpKind 单个词元的编辑操作
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffDelete
+	diffInsert
+)
+
+// diffOp 编辑脚本中的一步，a、b 分别为原文和修改后文本中的词元下标
+type diffOp struct {
+	kind diffOpKind
+	a, b int
+}
+
+// DiffText 对比原文和修改后的文本，返回插入、删除、替换三类修改
+//
+// 中文按字对比，连续的英文字母和数字按单词对比，位置以字符为单位。
+func DiffText(original, revised string) []models.TextChange {
+	a := tokenizeForDiff(original)
+	b := tokenizeForDiff(revised)
+	ops := diffTokens(a, b)
+
+	changes := []models.TextChange{}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == diffEqual {
+			i++
+			continue
+		}
+
+		// 收集一段连续的修改，删除的词元在原文中连续，插入的词元在修改后文本中连续
+		var deleted, inserted []diffToken
+		for ; i < len(ops) && ops[i].kind != diffEqual; i++ {
+			if ops[i].kind == diffDelete {
+				deleted = append(deleted, a[ops[i].a])
+			} else {
+				inserted = append(inserted, b[ops[i].b])
+			}
+		}
+
+		change := models.TextChange{ID: len(changes) + 1}
+		change.OriginalStart, change.OriginalEnd, change.Original = tokenSpan(deleted, a, ops, i, true)
+		change.RevisedStart, change.RevisedEnd, change.Revised = tokenSpan(inserted, b, ops, i, false)
+		switch {
+		case len(deleted) == 0:
+			change.Type = models.TextChangeInsert
+		case len(inserted) == 0:
+			change.Type = models.TextChangeDelete
+		default:
+			change.Type = models.TextChangeReplace
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// tokenSpan 计算一段修改在某一侧文本中的位置和内容
+//
+// 该侧没有词元时（纯插入或纯删除），位置取下一个未修改词元的起点，next 为这段修改之后的第一个操作下标。
+func tokenSpan(span []diffToken, tokens []diffToken, ops []diffOp, next int, original bool) (int, int, string) {
+	if len(span) > 0 {
+		var text strings.Builder
+		for _, token := range span {
+			text.WriteString(token.text)
+		}
+		last := span[len(span)-1]
+		return span[0].start, last.start + utf8.RuneCountInString(last.text), text.String()
+	}
+
+	pos := 0
+	if next < len(ops) {
+		index := ops[next].b
+		if original {
+			index = ops[next].a
+		}
+		pos = tokens[index].start
+	} else if len(tokens) > 0 {
+		last := tokens[len(tokens)-1]
+		pos = last.start + utf8.RuneCountInString(last.text)
+	}
+	return pos, pos, ""
+}
+
+// tokenizeForDiff 把文本切分为对比用的词元：连续的英文字母和数字为一个词元，其余每个字符单独成为一个词元
+func tokenizeForDiff(text string) []diffToken {
+	var tokens []diffToken
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		if isWordRune(runes[i]) {
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, diffToken{text: string(runes[i:j]), start: i})
+		i = j
+	}
+	return tokens
+}
+
+// isWordRune 判断字符是否属于英文单词或数字
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_')
+}
+
+// diffTokens 使用 Myers 差分算法计算两个词元序列之间的最短编辑脚本
+func diffTokens(a, b []diffToken) []diffOp {
+	size := (len(a)+len(b)+1)/2 + 1
+	d := &myersDiff{
+		a:  a,
+		b:  b,
+		vf: make([]int, 2*size+1),
+		vb: make([]int, 2*size+1),
+	}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+// myersDiff 线性空间的 Myers 差分：找到最短编辑路径中间的一段相同内容（middle snake），
+// 再分别对它前后两部分递归，内存只与文本长度成正比
+type myersDiff struct {
+	a, b   []diffToken
+	vf, vb []int // 正向和反向搜索中每条对角线能到达的最远位置，各层递归共用
+	ops    []diffOp
+}
+
+// compare 计算 a[a0:a1] 到 b[b0:b1] 的编辑脚本并追加到 ops
+func (d *myersDiff) compare(a0, a1, b0, b1 int) {
+	// 先去掉相同的前缀和后缀，只差一处插入或删除时其中一侧会变为空
+	for a0 < a1 && b0 < b1 && d.a[a0].text == d.b[b0].text {
+		d.ops = append(d.ops, diffOp{kind: diffEqual, a: a0, b: b0})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1-suffix && b0 < b1-suffix && d.a[a1-1-suffix].text == d.b[b1-1-suffix].text {
+		suffix++
+	}
+	a1 -= suffix
+	b1 -= suffix
+
+	switch {
+	case a0 == a1:
+		for j := b0; j < b1; j++ {
+			d.ops = append(d.ops, diffOp{kind: diffInsert, a: a0, b: j})
+		}
+	case b0 == b1:
+		for i := a0; i < a1; i++ {
+			d.ops = append(d.ops, diffOp{kind: diffDelete, a: i, b: b0})
+		}
+	default:
+		x, y, ok := d.bisect(a0, a1, b0, b1)
+		if !ok {
+			// 差异过大，整体视为删除原文并插入新文本
+			for i := a0; i < a1; i++ {
+				d.ops = append(d.ops, diffOp{kind: diffDelete, a: i, b: b0})
+			}
+			for j := b0; j < b1; j++ {
+				d.ops = append(d.ops, diffOp{kind: diffInsert, a: a1, b: j})
+			}
+			break
+		}
+		d.compare(a0, x, b0, y)
+		d.compare(x, a1, y, b1)
+	}
+
+	for i := suffix; i > 0; i-- {
+		d.ops = append(d.ops, diffOp{kind: diffEqual, a: a1 + suffix - i, b: b1 + suffix - i})
+	}
+}
+
+// bisect 从两端同时搜索最短编辑路径，返回两个方向相遇的位置 (x, y)，
+// 最短编辑路径经过该点，前后两部分可以分别计算。编辑距离超过 maxDiffEdits 时返回 false。
+func (d *myersDiff) bisect(a0, a1, b0, b1 int) (int, int, bool) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	front := delta%2 != 0 // 编辑距离为奇数时在正向搜索中相遇，否则在反向搜索中相遇
+	maxD := min((n+m+1)/2, (maxDiffEdits+1)/2)
+
+	// vf[offset+k] 为正向搜索在对角线 k（x-y=k）上最远的 x；vb[offset+k] 为反向搜索在
+	// 反向对角线 k 上距终点最远的距离，对应正向对角线 delta-k；-1 表示尚未到达
+	offset := maxD + 1
+	vf, vb := d.vf[:2*offset+1], d.vb[:2*offset+1]
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	// 路径越过右边界或下边界后，外侧的对角线不再需要搜索
+	var fStart, fEnd, bStart, bEnd int
+	for step := 0; step <= maxD; step++ {
+		for k := -step + fStart; k <= step-fEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a0+x].text == d.b[b0+y].text {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				if c := offset + delta - k; c >= 0 && c < len(vb) && vb[c] != -1 && x >= n-vb[c] {
+					return a0 + x, b0 + y, true
+				}
+			}
+		}
+
+		for k := -step + bStart; k <= step-bEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a1-1-x].text == d.b[b1-1-y].text {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !front:
+				if c := offset + delta - k; c >= 0 && c < len(vf) && vf[c] != -1 && vf[c] >= n-x {
+					fx := vf[c]
+					return a0 + fx, b0 + fx - (c - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// ApplyTextChanges 在原文上应用被接受的修改，未被接受的修改保留原文
+func ApplyTextChanges(original string, changes []models.TextChange, accepted map[int]bool) string {
+	runes := []rune(original)
+	var merged strings.Builder
+	pos := 0
+	for _, change := range changes {
+		merged.WriteString(string(runes[pos:change.OriginalStart]))
+		if accepted[change.ID] {
+			merged.WriteString(change.Revised)
+		} else {
+			merged.WriteString(change.Original)
+		}
+		pos = change.OriginalEnd
+	}
+	merged.WriteString(string(runes[pos:]))
+	return merged.String()
+}
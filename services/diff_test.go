@@ -0,0 +1,125 @@
+package services
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"essay-go/models"
+)
+
+func TestDiffTextRoundTrip(t *testing.T) {
+	long := strings.Repeat("春天来了，小草从地下钻出来。", 200)
+	tests := []struct {
+		name     string
+		original string
+		revised  string
+		changes  int // 期望的修改处数，-1 表示不检查
+	}{
+		{"相同", "今天天气很好。", "今天天气很好。", 0},
+		{"都为空", "", "", 0},
+		{"从空到有", "", "今天天气很好。", 1},
+		{"从有到空", "今天天气很好。", "", 1},
+		{"插入", "今天天气好。", "今天天气很好。", 1},
+		{"删除", "今天天气很好。", "今天天气好。", 1},
+		{"替换", "今天天气很好。", "今天天气不错。", 1},
+		{"开头和结尾", "我今天去公园", "他今天去学校", 2},
+		{"英文按单词", "I like apple pie.", "I love apple pies.", 2},
+		{"中英混合", "我用Go写了一个web服务。", "我用Go语言写了一个Web服务！", 3},
+		{"重复内容", "啊啊啊啊啊", "啊啊啊", 1},
+		{"多处修改", "小明早上起床，吃了早饭，然后去学校。", "小红早上很早起床，吃完早饭，就去学校了。", -1},
+		{"长文本", long, strings.ReplaceAll(long, "钻", "冒"), 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := DiffText(tt.original, tt.revised)
+			checkDiff(t, tt.original, tt.revised, changes)
+			if tt.changes >= 0 && len(changes) != tt.changes {
+				t.Errorf("修改处数 = %d, 期望 %d: %+v", len(changes), tt.changes, changes)
+			}
+		})
+	}
+}
+
+func TestDiffTextMinimal(t *testing.T) {
+	// 用小字母表生成大量相似的文本，对比编辑距离与最长公共子序列计算的结果
+	rng := rand.New(rand.NewSource(1))
+	letters := []rune("春夏秋冬。")
+	randomText := func(n int) string {
+		runes := make([]rune, n)
+		for i := range runes {
+			runes[i] = letters[rng.Intn(len(letters))]
+		}
+		return string(runes)
+	}
+
+	for i := 0; i < 500; i++ {
+		original := randomText(rng.Intn(30))
+		revised := randomText(rng.Intn(30))
+		changes := DiffText(original, revised)
+		checkDiff(t, original, revised, changes)
+
+		edits := 0
+		for _, change := range changes {
+			edits += len([]rune(change.Original)) + len([]rune(change.Revised))
+		}
+		lcs := lcsLength([]rune(original), []rune(revised))
+		if want := len([]rune(original)) + len([]rune(revised)) - 2*lcs; edits != want {
+			t.Fatalf("DiffText(%q, %q) 编辑了 %d 个字, 最短为 %d", original, revised, edits, want)
+		}
+	}
+}
+
+func TestDiffTextTooManyEdits(t *testing.T) {
+	original := strings.Repeat("甲乙", maxDiffEdits)
+	revised := strings.Repeat("丙丁", maxDiffEdits)
+	changes := DiffText(original, revised)
+	checkDiff(t, original, revised, changes)
+	if len(changes) != 1 || changes[0].Type != models.TextChangeReplace {
+		t.Errorf("差异过大时应整体替换, 得到 %d 处修改", len(changes))
+	}
+}
+
+// checkDiff 检查修改的位置与内容一致，全部接受得到修改后的文本，全部拒绝得到原文
+func checkDiff(t *testing.T, original, revised string, changes []models.TextChange) {
+	t.Helper()
+	originalRunes, revisedRunes := []rune(original), []rune(revised)
+	accepted := make(map[int]bool)
+	for i, change := range changes {
+		if change.ID != i+1 {
+			t.Fatalf("第 %d 处修改的 ID = %d", i+1, change.ID)
+		}
+		if got := string(originalRunes[change.OriginalStart:change.OriginalEnd]); got != change.Original {
+			t.Fatalf("修改 %d 在原文中的位置为 %q, 内容为 %q", change.ID, got, change.Original)
+		}
+		if got := string(revisedRunes[change.RevisedStart:change.RevisedEnd]); got != change.Revised {
+			t.Fatalf("修改 %d 在修改后文本中的位置为 %q, 内容为 %q", change.ID, got, change.Revised)
+		}
+		accepted[change.ID] = true
+	}
+
+	if got := ApplyTextChanges(original, changes, accepted); got != revised {
+		t.Errorf("接受全部修改 = %q, 期望 %q", got, revised)
+	}
+	if got := ApplyTextChanges(original, changes, nil); got != original {
+		t.Errorf("拒绝全部修改 = %q, 期望 %q", got, original)
+	}
+}
+
+// lcsLength 以动态规划计算最长公共子序列的长度
+func lcsLength(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
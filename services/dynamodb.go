@@ -17,17 +17,72 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// ErrDestructiveInProduction 生产环境中拒绝执行删除数据的操作
+var ErrDestructiveInProduction = errors.New("生产环境中禁止执行删除数据的操作")
+
+// tableWaitTimeout 等待表创建或删除完成的最长时间
+const tableWaitTimeout = 2 * time.Minute
+
+// seqIndexName 按变更序号查询作文的本地二级索引，只有在创建表时才能添加
+const seqIndexName = "seq-index"
+
 // DynamoDBClient 是 DynamoDB 客户端的包装
 type DynamoDBClient struct {
 	client    *dynamodb.Client
 	tableName string
+	// hasSeqIndex 表是否有 seq-index，在此之前创建的表增量同步时需要读取用户的全部作文
+	hasSeqIndex bool
+}
+
+// NewDynamoDBClient 创建 DynamoDB 客户端，表不存在时创建，已存在时不做任何修改
+func NewDynamoDBClient(region, tableName string) (*DynamoDBClient, error) {
+	client, err := newDynamoDBAPI(region)
+	if err != nil {
+		return nil, err
+	}
+
+	// 确保表存在，无法访问 DynamoDB 时返回错误
+	table, err := ensureTableExists(client, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DynamoDBClient{
+		client:    client,
+		tableName: tableName,
+	}
+	for _, index := range table.LocalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == seqIndexName {
+			db.hasSeqIndex = true
+		}
+	}
+	if !db.hasSeqIndex {
+		log.Printf("表 %s 没有 %s 索引，增量同步将读取用户的全部作文", tableName, seqIndexName)
+	}
+	return db, nil
 }
 
-// 全局 DynamoDB 客户端实例
-var dynamoDBClient *DynamoDBClient
+// ResetDynamoDBTable 删除并重新创建作文表，所有用户的作文都会被清空
+//
+// 只能通过管理命令显式调用，production 为 true 时拒绝执行。
+func ResetDynamoDBTable(region, tableName string, production bool) error {
+	if production {
+		return ErrDestructiveInProduction
+	}
+
+	client, err := newDynamoDBAPI(region)
+	if err != nil {
+		return err
+	}
+	if err := deleteTable(client, tableName); err != nil {
+		return err
+	}
+	_, err = createTable(client, tableName)
+	return err
+}
 
-// InitDynamoDB 初始化 DynamoDB 客户端
-func InitDynamoDB(region, tableName string) {
+// newDynamoDBAPI 加载 AWS 配置并创建 DynamoDB 客户端
+func newDynamoDBAPI(region string) (*dynamodb.Client, error) {
 	// 从环境变量获取 AWS 凭证
 	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
 	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
@@ -46,58 +101,13 @@ func InitDynamoDB(region, tableName string) {
 	
 	cfg, err := awsconfig.LoadDefaultConfig(context.TODO(), cfgOptions...)
 	if err != nil {
-		log.Printf("无法加载 AWS 配置: %v", err)
-		return
+		return nil, fmt.Errorf("无法加载 AWS 配置: %w", err)
 	}
 
-	// 创建 DynamoDB 客户端
-	client := dynamodb.NewFromConfig(cfg)
-
-	// 创建并存储客户端实例
-	dynamoDBClient = &DynamoDBClient{
-		client:    client,
-		tableName: tableName,
-	}
-
-	// 确保表存在
-	ensureTableExists(client, tableName)
-}
-
-// GetDynamoDBClient 返回 DynamoDB 客户端实例
-func GetDynamoDBClient() *DynamoDBClient {
-	return dynamoDBClient
+	return dynamodb.NewFromConfig(cfg), nil
 }
 
-// 创建标记文件路径
-const initFlagFile = "data/dynamodb_initialized.flag"
-
-// checkInitialized 检查是否已经初始化过表
-func checkInitialized() bool {
-	// 检查标记文件是否存在
-	_, err := os.Stat(initFlagFile)
-	return err == nil
-}
-
-// markInitialized 标记表已经初始化
-func markInitialized() error {
-	// 确保 data 目录存在
-	if err := os.MkdirAll("data", 0755); err != nil {
-		return err
-	}
-	
-	// 创建标记文件
-	f, err := os.Create(initFlagFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	
-	// 写入初始化时间
-	_, err = f.WriteString(fmt.Sprintf("DynamoDB table initialized at %s\n", time.Now().Format(time.RFC3339)))
-	return err
-}
-
-// deleteTable 删除现有表
+// deleteTable 删除现有表并等待删除完成，表不存在时直接返回
 func deleteTable(client *dynamodb.Client, tableName string) error {
 	log.Printf("尝试删除表 %s...", tableName)
 	_, err := client.DeleteTable(context.TODO(), &dynamodb.DeleteTableInput{
@@ -116,98 +126,135 @@ func deleteTable(client *dynamodb.Client, tableName string) error {
 		return err
 	}
 
-	log.Printf("表 %s 删除成功", tableName)
+	// 等待表删除完成，否则无法以相同的名称重新创建
+	log.Printf("等待表 %s 删除完成...", tableName)
+	waiter := dynamodb.NewTableNotExistsWaiter(client)
+	if err := waiter.Wait(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	}, tableWaitTimeout); err != nil {
+		return fmt.Errorf("等待表 %s 删除完成失败: %w", tableName, err)
+	}
 
-	// 跳过等待表删除完成的步骤，直接继续
-	log.Printf("跳过等待表删除完成的步骤，直接继续")
-	
-	// 等待一小段时间，给 AWS 一些时间处理删除请求
-	time.Sleep(2 * time.Second)
-	
+	log.Printf("表 %s 删除成功", tableName)
 	return nil
 }
 
-// ensureTableExists 检查表是否存在，如果不存在则创建表
-func ensureTableExists(client *dynamodb.Client, tableName string) {
-	// 强制重新创建表，删除初始化标记文件
-	// 删除标记文件
-	os.Remove(initFlagFile)
-	
-	// 删除现有表并重新创建
-	deleteTable(client, tableName)
-
-	// 检查表是否存在
-	_, err := client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
+// ensureTableExists 检查表是否存在，只有确认不存在时才创建表，不会删除或修改已有的表
+func ensureTableExists(client *dynamodb.Client, tableName string) (*types.TableDescription, error) {
+	resp, err := client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
 		TableName: aws.String(tableName),
 	})
+	if err == nil {
+		log.Printf("表 %s 已存在", tableName)
+		return resp.Table, nil
+	}
 
-	if err != nil {
-		log.Printf("表 %s 不存在，错误详情: %v", tableName, err)
-		
-		// 创建表
-		log.Printf("尝试创建表 %s...", tableName)
-		_, createErr := client.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
-			TableName: aws.String(tableName),
-			AttributeDefinitions: []types.AttributeDefinition{
-				{
-					AttributeName: aws.String("username"),
-					AttributeType: types.ScalarAttributeTypeS,
-				},
-				{
-					AttributeName: aws.String("id"),
-					AttributeType: types.ScalarAttributeTypeN,
-				},
+	// 凭证错误、网络错误等无法确认表是否存在的情况直接返回，不尝试创建
+	var notFoundErr *types.ResourceNotFoundException
+	if !errors.As(err, &notFoundErr) {
+		return nil, fmt.Errorf("检查表 %s 失败: %w", tableName, err)
+	}
+
+	log.Printf("表 %s 不存在", tableName)
+	return createTable(client, tableName)
+}
+
+// createTable 创建作文表并等待创建完成，返回创建后的表信息
+func createTable(client *dynamodb.Client, tableName string) (*types.TableDescription, error) {
+	log.Printf("尝试创建表 %s...", tableName)
+	_, err := client.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("username"),
+				AttributeType: types.ScalarAttributeTypeS,
 			},
-			KeySchema: []types.KeySchemaElement{
-				{
-					AttributeName: aws.String("username"),
-					KeyType:       types.KeyTypeHash,
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeN,
+			},
+			{
+				AttributeName: aws.String("seq"),
+				AttributeType: types.ScalarAttributeTypeN,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("username"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		// 增量同步按变更序号查询，ID 计数器条目没有 seq 属性，不会出现在索引中
+		LocalSecondaryIndexes: []types.LocalSecondaryIndex{
+			{
+				IndexName: aws.String(seqIndexName),
+				KeySchema: []types.KeySchemaElement{
+					{
+						AttributeName: aws.String("username"),
+						KeyType:       types.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("seq"),
+						KeyType:       types.KeyTypeRange,
+					},
 				},
-				{
-					AttributeName: aws.String("id"),
-					KeyType:       types.KeyTypeRange,
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
 				},
 			},
-			ProvisionedThroughput: &types.ProvisionedThroughput{
-				ReadCapacityUnits:  aws.Int64(5),
-				WriteCapacityUnits: aws.Int64(5),
-			},
-		})
-
-		if createErr != nil {
-			log.Printf("创建表失败: %v", createErr)
-			return
-		}
-
-		// 等待表创建完成
-		log.Printf("等待表创建完成...")
-		waiter := dynamodb.NewTableExistsWaiter(client)
-		if err := waiter.Wait(context.TODO(), &dynamodb.DescribeTableInput{
-			TableName: aws.String(tableName),
-		}, 2*time.Minute); err != nil {
-			log.Printf("等待表创建完成失败: %v", err)
-			return
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+	})
+	if err != nil {
+		// 多个实例同时启动时，表可能已被其他实例创建
+		var inUseErr *types.ResourceInUseException
+		if !errors.As(err, &inUseErr) {
+			log.Printf("创建表失败: %v", err)
+			return nil, fmt.Errorf("创建表 %s 失败: %w", tableName, err)
 		}
+		log.Printf("表 %s 正在由其他实例创建", tableName)
+	}
 
-		log.Printf("表 %s 创建成功", tableName)
-		
-		// 标记已初始化
-		if err := markInitialized(); err != nil {
-			log.Printf("标记初始化状态失败: %v", err)
-		}
-	} else {
-		log.Printf("表 %s 已存在", tableName)
+	// 等待表创建完成
+	log.Printf("等待表创建完成...")
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	resp, err := waiter.WaitForOutput(context.TODO(), &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	}, tableWaitTimeout)
+	if err != nil {
+		log.Printf("等待表创建完成失败: %v", err)
+		return nil, fmt.Errorf("等待表 %s 创建完成失败: %w", tableName, err)
 	}
+
+	log.Printf("表 %s 创建成功", tableName)
+	return resp.Table, nil
 }
 
-// getMaxID 获取用户的最大 ID
+// idCounterID 每个用户的 ID 计数器条目使用的排序键，作文的 ID 从 1 开始，查询作文时需要排除
+const idCounterID = 0
+
+// maxIDAllocationAttempts 新分配的 ID 已被占用时最多重新分配的次数
+const maxIDAllocationAttempts = 3
+
+// maxSeqAttempts 同一用户的并发写入抢占变更序号时最多重试的次数
+const maxSeqAttempts = 5
+
+// getMaxID 获取用户作文的最大 ID（包括已软删除的），不包括计数器条目
 func (db *DynamoDBClient) getMaxID(username string) (int64, error) {
 	// 查询用户的所有作文
 	resp, err := db.client.Query(context.TODO(), &dynamodb.QueryInput{
 		TableName:              aws.String(db.tableName),
-		KeyConditionExpression: aws.String("username = :username"),
+		KeyConditionExpression: aws.String("username = :username AND id > :counter"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":username": &types.AttributeValueMemberS{Value: username},
+			":counter":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", idCounterID)},
 		},
 		Limit: aws.Int32(1), // 只需要一个结果
 		ScanIndexForward: aws.Bool(false), // 降序排序，最大的 ID 在前面
@@ -234,8 +281,79 @@ func (db *DynamoDBClient) getMaxID(username string) (int64, error) {
 	return essay.ID, nil
 }
 
-// SaveEssay 保存作文到 DynamoDB
-func (db *DynamoDBClient) SaveEssay(essay models.Essay) error {
+// counterKey 返回用户 ID 计数器条目的主键
+func (db *DynamoDBClient) counterKey(username string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"username": &types.AttributeValueMemberS{Value: username},
+		"id":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", idCounterID)},
+	}
+}
+
+// allocateID 原子地递增用户的 ID 计数器并返回新的 ID，多个服务实例同时分配也不会得到相同的 ID
+//
+// 计数器不存在时（例如在引入计数器之前保存过作文的用户）先以当前最大 ID 初始化。
+func (db *DynamoDBClient) allocateID(username string) (int64, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(db.tableName),
+		Key:                 db.counterKey(username),
+		UpdateExpression:    aws.String("ADD next_id :one"),
+		ConditionExpression: aws.String("attribute_exists(next_id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+	resp, err := db.client.UpdateItem(context.TODO(), input)
+	if isConditionalCheckFailed(err) {
+		if err := db.syncCounter(username); err != nil {
+			return 0, err
+		}
+		input.ConditionExpression = nil
+		resp, err = db.client.UpdateItem(context.TODO(), input)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("分配作文 ID 失败: %w", err)
+	}
+
+	var counter struct {
+		NextID int64 `dynamodbav:"next_id"`
+	}
+	if err := attributevalue.UnmarshalMap(resp.Attributes, &counter); err != nil {
+		return 0, fmt.Errorf("解析作文 ID 计数器失败: %w", err)
+	}
+	return counter.NextID, nil
+}
+
+// syncCounter 把用户的 ID 计数器提高到当前最大 ID，计数器已经不小于最大 ID 时不做修改
+func (db *DynamoDBClient) syncCounter(username string) error {
+	maxID, err := db.getMaxID(username)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName:           aws.String(db.tableName),
+		Key:                 db.counterKey(username),
+		UpdateExpression:    aws.String("SET next_id = :max"),
+		ConditionExpression: aws.String("attribute_not_exists(next_id) OR next_id < :max"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":max": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", maxID)},
+		},
+	})
+	if err != nil && !isConditionalCheckFailed(err) {
+		return fmt.Errorf("更新作文 ID 计数器失败: %w", err)
+	}
+	return nil
+}
+
+// isConditionalCheckFailed 判断是否为条件写入的条件不满足
+func isConditionalCheckFailed(err error) bool {
+	var conditionErr *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionErr)
+}
+
+// SaveEssay 保存作文到 DynamoDB，新作文分配的 ID 会写回 essay
+func (db *DynamoDBClient) SaveEssay(essay *models.Essay) error {
 	// 确保更新时间格式正确
 	if essay.UpdatedAt == "" {
 		essay.UpdatedAt = time.Now().Format(time.RFC3339)
@@ -247,40 +365,168 @@ func (db *DynamoDBClient) SaveEssay(essay models.Essay) error {
 		return fmt.Errorf("用户名不能为空")
 	}
 
-	// 如果 ID 为 0，获取新的 ID
+	if essay.ID < 0 {
+		return fmt.Errorf("无效的作文 ID: %d", essay.ID)
+	}
+
+	// 新作文从计数器分配 ID，并且只在该 ID 未被占用时写入
 	if essay.ID == 0 {
-		maxID, err := db.getMaxID(essay.Username)
-		if err != nil {
-			log.Printf("获取最大 ID 失败: %v", err)
-			return err
+		for attempt := 1; ; attempt++ {
+			id, err := db.allocateID(essay.Username)
+			if err != nil {
+				log.Printf("分配作文 ID 失败: %v", err)
+				return err
+			}
+			essay.ID = id
+			log.Printf("为新作文分配 ID: %d", essay.ID)
+
+			essay.Version = 1
+			err = db.putEssay(essay, "attribute_not_exists(id)", nil)
+			if !isConditionalCheckFailed(err) {
+				return err
+			}
+			// 该 ID 已被客户端指定 ID 保存的作文占用，把计数器提高到最大 ID 后重新分配
+			essay.ID, essay.Version = 0, 0
+			if attempt >= maxIDAllocationAttempts {
+				return fmt.Errorf("分配作文 ID 失败: 连续 %d 次与已有作文冲突", attempt)
+			}
+			if err := db.syncCounter(essay.Username); err != nil {
+				return err
+			}
 		}
-		essay.ID = maxID + 1
-		log.Printf("为新作文分配 ID: %d", essay.ID)
 	}
 
+	// 已有作文只在版本号与客户端读到的一致时写入，避免覆盖其他设备的修改
+	expected := essay.Version
+	essay.Version = expected + 1
+	condition, values := versionCondition(expected)
+	err := db.putEssay(essay, condition, values)
+	if isConditionalCheckFailed(err) {
+		essay.Version = expected
+		return db.conflict(essay.Username, essay.ID)
+	}
+	if err != nil {
+		essay.Version = expected
+	}
+	return err
+}
+
+// versionCondition 返回要求存储中的版本号等于 expected 的写入条件，
+// expected 为 0 表示作文还不存在，或是引入版本号之前保存的旧数据
+func versionCondition(expected int64) (string, map[string]types.AttributeValue) {
+	if expected == 0 {
+		return "attribute_not_exists(id) OR attribute_not_exists(version)", nil
+	}
+	return "version = :expected", map[string]types.AttributeValue{
+		":expected": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", expected)},
+	}
+}
+
+// conflict 读取存储中的最新版本，构造版本冲突错误
+func (db *DynamoDBClient) conflict(username string, essayID int64) error {
+	current, err := db.GetEssay(username, essayID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return ErrEssayNotFound
+	}
+	log.Printf("作文版本冲突, 用户名: %s, ID: %d, 当前版本: %d", username, essayID, current.Version)
+	return &VersionConflictError{Current: current}
+}
+
+// putEssay 写入作文并分配新的变更序号，condition 不为空时只在条件满足时写入
+//
+// 作文和用户计数器条目上的 next_seq 在同一个事务中写入，并要求 next_seq 仍是读到的值，
+// 同一用户的写入按序号依次提交：读到序号 n 时，序号不大于 n 的写入都已经完成，增量同步不会漏掉作文。
+// 条件不满足时返回 *types.ConditionalCheckFailedException，与普通的条件写入一致。
+func (db *DynamoDBClient) putEssay(essay *models.Essay, condition string, values map[string]types.AttributeValue) error {
 	log.Printf("尝试保存作文, ID: %d, 标题: %s, 用户名: %s, 更新时间: %s", 
 		essay.ID, essay.Title, essay.Username, essay.UpdatedAt)
 
-	// 将作文转换为 DynamoDB 属性值
-	item, err := attributevalue.MarshalMap(essay)
-	if err != nil {
-		log.Printf("将作文转换为 DynamoDB 属性值失败: %v", err)
+	for attempt := 1; ; attempt++ {
+		seq, err := db.currentSeq(essay.Username)
+		if err != nil {
+			return err
+		}
+		essay.Seq = seq + 1
+
+		// 将作文转换为 DynamoDB 属性值
+		item, err := attributevalue.MarshalMap(essay)
+		if err != nil {
+			log.Printf("将作文转换为 DynamoDB 属性值失败: %v", err)
+			return err
+		}
+
+		put := &types.Put{
+			TableName: aws.String(db.tableName),
+			Item:      item,
+		}
+		if condition != "" {
+			put.ConditionExpression = aws.String(condition)
+			put.ExpressionAttributeValues = values
+		}
+		_, err = db.client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Update: &types.Update{
+					TableName:           aws.String(db.tableName),
+					Key:                 db.counterKey(essay.Username),
+					UpdateExpression:    aws.String("SET next_seq = :seq"),
+					ConditionExpression: aws.String("attribute_not_exists(next_seq) OR next_seq = :prev"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":seq":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", essay.Seq)},
+						":prev": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", seq)},
+					},
+				}},
+				{Put: put},
+			},
+		})
+
+		var canceledErr *types.TransactionCanceledException
+		if errors.As(err, &canceledErr) && len(canceledErr.CancellationReasons) == 2 {
+			reasons := canceledErr.CancellationReasons
+			if aws.ToString(reasons[1].Code) == "ConditionalCheckFailed" {
+				essay.Seq = 0
+				return &types.ConditionalCheckFailedException{Message: reasons[1].Message}
+			}
+			// 序号已被同一用户的其他写入占用，或与其他写入的事务冲突，重新读取后重试
+			retryable := aws.ToString(reasons[0].Code) == "ConditionalCheckFailed" ||
+				aws.ToString(reasons[0].Code) == "TransactionConflict" ||
+				aws.ToString(reasons[1].Code) == "TransactionConflict"
+			if retryable && attempt < maxSeqAttempts {
+				continue
+			}
+		}
+
+		if err != nil {
+			essay.Seq = 0
+			log.Printf("保存作文到 DynamoDB 失败: %v", err)
+		} else {
+			log.Printf("作文保存成功, 用户名: %s, ID: %d, 变更序号: %d", essay.Username, essay.ID, essay.Seq)
+		}
 		return err
 	}
+}
 
-	// 保存到 DynamoDB
-	_, err = db.client.PutItem(context.TODO(), &dynamodb.PutItemInput{
-		TableName: aws.String(db.tableName),
-		Item:      item,
+// currentSeq 返回用户当前的最大变更序号，还没有分配过序号时返回 0
+func (db *DynamoDBClient) currentSeq(username string) (int64, error) {
+	resp, err := db.client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName:            aws.String(db.tableName),
+		Key:                  db.counterKey(username),
+		ProjectionExpression: aws.String("next_seq"),
+		ConsistentRead:       aws.Bool(true),
 	})
-
 	if err != nil {
-		log.Printf("保存作文到 DynamoDB 失败: %v", err)
-	} else {
-		log.Printf("作文保存成功, 用户名: %s, ID: %d", essay.Username, essay.ID)
+		return 0, fmt.Errorf("读取变更序号失败: %w", err)
 	}
 
-	return err
+	var counter struct {
+		NextSeq int64 `dynamodbav:"next_seq"`
+	}
+	if err := attributevalue.UnmarshalMap(resp.Item, &counter); err != nil {
+		return 0, fmt.Errorf("解析变更序号失败: %w", err)
+	}
+	return counter.NextSeq, nil
 }
 
 // GetEssaysByUsername 根据用户名获取所有作文
@@ -290,35 +536,18 @@ func (db *DynamoDBClient) GetEssaysByUsername(username string) ([]models.Essay,
 	// 使用 Query 操作直接查询主键
 	resp, err := db.client.Query(context.TODO(), &dynamodb.QueryInput{
 		TableName:              aws.String(db.tableName),
-		KeyConditionExpression: aws.String("username = :username"),
+		KeyConditionExpression: aws.String("username = :username AND id > :counter"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":username": &types.AttributeValueMemberS{Value: username},
+			":counter":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", idCounterID)},
 		},
-		// 按 ID 降序排序，最新的在前面
+		// 按 ID 降序排序，最新的在前面，不包括 ID 计数器条目
 		ScanIndexForward: aws.Bool(false),
 	})
 
 	if err != nil {
+		// 表不存在时不在这里重建，以免掩盖配置错误或误删的数据
 		log.Printf("从 DynamoDB 获取作文失败: %v", err)
-		
-		// 检查是否是表不存在的错误
-		var notFoundErr *types.ResourceNotFoundException
-		if ok := errors.As(err, &notFoundErr); ok {
-			log.Printf("表不存在，尝试初始化表...")
-			
-			// 删除标记文件，强制重新初始化
-			os.Remove(initFlagFile)
-			
-			// 初始化表
-			ensureTableExists(db.client, db.tableName)
-			
-			// 等待表创建完成
-			time.Sleep(5 * time.Second)
-			
-			// 重新查询
-			return db.GetEssaysByUsername(username)
-		}
-		
 		return nil, err
 	}
 
@@ -345,60 +574,126 @@ func (db *DynamoDBClient) GetEssaysByUsername(username string) ([]models.Essay,
 	return activeEssays, nil
 }
 
-// DeleteEssay 从 DynamoDB 软删除作文
-func (db *DynamoDBClient) DeleteEssay(username string, essayID int64) error {
-	log.Printf("尝试软删除作文, 用户名: %s, ID: %d", username, essayID)
-	
-	// 首先获取该作文
-	resp, err := db.client.Query(context.TODO(), &dynamodb.QueryInput{
-		TableName:              aws.String(db.tableName),
-		KeyConditionExpression: aws.String("username = :username AND id = :id"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":username": &types.AttributeValueMemberS{Value: username},
-			":id":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", essayID)},
+// GetEssay 获取用户的一篇作文，未找到时返回 nil
+func (db *DynamoDBClient) GetEssay(username string, essayID int64) (*models.Essay, error) {
+	// ID 计数器条目不是作文
+	if essayID <= idCounterID {
+		return nil, nil
+	}
+	resp, err := db.client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key: map[string]types.AttributeValue{
+			"username": &types.AttributeValueMemberS{Value: username},
+			"id":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", essayID)},
 		},
-		Limit: aws.Int32(1),
 	})
-
 	if err != nil {
 		log.Printf("获取作文失败: %v", err)
-		return err
+		return nil, err
 	}
-
-	if len(resp.Items) == 0 {
-		log.Printf("未找到要删除的作文, 用户名: %s, ID: %d", username, essayID)
-		return fmt.Errorf("未找到要删除的作文")
+	if resp.Item == nil {
+		return nil, nil
 	}
 
-	// 解析作文
 	var essay models.Essay
-	err = attributevalue.UnmarshalMap(resp.Items[0], &essay)
-	if err != nil {
+	if err := attributevalue.UnmarshalMap(resp.Item, &essay); err != nil {
 		log.Printf("解析作文失败: %v", err)
-		return err
+		return nil, err
 	}
+	return &essay, nil
+}
 
-	// 设置删除时间
-	essay.DeletedAt = time.Now().Format(time.RFC3339)
+// DeleteEssay 从 DynamoDB 软删除作文，version 不为 0 时要求与存储中的版本一致
+func (db *DynamoDBClient) DeleteEssay(username string, essayID int64, version int64) error {
+	log.Printf("尝试软删除作文, 用户名: %s, ID: %d", username, essayID)
 
-	// 将修改后的作文保存回 DynamoDB
-	item, err := attributevalue.MarshalMap(essay)
+	// 首先获取该作文
+	essay, err := db.GetEssay(username, essayID)
 	if err != nil {
-		log.Printf("将作文转换为 DynamoDB 属性值失败: %v", err)
 		return err
 	}
+	if essay == nil {
+		log.Printf("未找到要删除的作文, 用户名: %s, ID: %d", username, essayID)
+		return ErrEssayNotFound
+	}
+	if version != 0 && version != essay.Version {
+		return &VersionConflictError{Current: essay}
+	}
+	if essay.DeletedAt != "" {
+		return nil
+	}
 
-	// 更新记录
-	_, err = db.client.PutItem(context.TODO(), &dynamodb.PutItemInput{
-		TableName: aws.String(db.tableName),
-		Item:      item,
-	})
+	// 设置删除时间，并以读到的版本号为条件写回，期间被其他设备修改时返回冲突
+	expected := essay.Version
+	now := time.Now().Format(time.RFC3339)
+	essay.DeletedAt = now
+	essay.UpdatedAt = now
+	essay.Version = expected + 1
+	condition, values := versionCondition(expected)
+	err = db.putEssay(essay, condition, values)
+	if isConditionalCheckFailed(err) {
+		return db.conflict(username, essayID)
+	}
 
 	if err != nil {
 		log.Printf("软删除作文失败: %v", err)
 	} else {
 		log.Printf("作文软删除成功, 用户名: %s, ID: %d", username, essayID)
 	}
-	
 	return err
 }
+
+
+// GetEssayChanges 返回变更序号大于 since 的作文（包括已软删除的）和用户当前的最大变更序号
+//
+// 先读取当前序号再查询，查询期间新写入的作文即使已经返回，下次同步时也会再返回一次，不会遗漏。
+func (db *DynamoDBClient) GetEssayChanges(username string, since int64) ([]models.Essay, int64, error) {
+	cursor, err := db.currentSeq(username)
+	if err != nil {
+		return nil, 0, err
+	}
+	if since > cursor {
+		since = 0
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:      aws.String(db.tableName),
+		ConsistentRead: aws.Bool(true),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":username": &types.AttributeValueMemberS{Value: username},
+		},
+	}
+	switch {
+	case since > 0 && db.hasSeqIndex:
+		input.IndexName = aws.String(seqIndexName)
+		input.KeyConditionExpression = aws.String("username = :username AND seq > :since")
+		input.ExpressionAttributeValues[":since"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", since)}
+	default:
+		// 全量同步需要包括引入变更序号之前保存的作文，它们没有 seq 属性，不在索引中
+		input.KeyConditionExpression = aws.String("username = :username AND id > :counter")
+		input.ExpressionAttributeValues[":counter"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", idCounterID)}
+		if since > 0 {
+			input.FilterExpression = aws.String("seq > :since")
+			input.ExpressionAttributeValues[":since"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", since)}
+		}
+	}
+
+	changes := []models.Essay{}
+	paginator := dynamodb.NewQueryPaginator(db.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			log.Printf("获取作文变更失败: %v", err)
+			return nil, 0, err
+		}
+		var essays []models.Essay
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &essays); err != nil {
+			log.Printf("解析 DynamoDB 结果失败: %v", err)
+			return nil, 0, err
+		}
+		changes = append(changes, essays...)
+	}
+
+	log.Printf("用户 %s 自序号 %d 以来有 %d 篇作文变更, 当前序号: %d", username, since, len(changes), cursor)
+	return changes, cursor, nil
+}
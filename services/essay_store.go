@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"essay-go/config"
+	"essay-go/models"
+	"fmt"
+	"log"
+)
+
+// ErrEssayNotFound 要修改或删除的作文不存在
+var ErrEssayNotFound = errors.New("未找到作文")
+
+// VersionConflictError 作文已在其他设备上修改，写入时提供的版本号与存储中的不一致
+type VersionConflictError struct {
+	Current *models.Essay // 存储中的最新版本
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("作文 %d 已在其他设备上修改（当前版本 %d）", e.Current.ID, e.Current.Version)
+}
+
+// EssayStore 作文存储，DynamoDB 和本地文件各有一个实现，由 ESSAY_STORE 配置选择
+//
+// 每次写入都会把作文的版本号加一。修改已有作文时 essay.Version 必须是读取时的版本号，
+// 与存储中的不一致说明作文已被其他设备修改，返回 *VersionConflictError 而不是覆盖。
+//
+// 每次写入（包括软删除）还会从用户的变更序号中分配新的 essay.Seq，序号只增不减，
+// 客户端记录上次同步时的最大序号，之后只需获取序号更大的作文。
+type EssayStore interface {
+	// SaveEssay 保存作文并把新的版本号写回 essay，ID 为 0 时分配新的 ID
+	SaveEssay(essay *models.Essay) error
+	// GetEssaysByUsername 返回用户所有未删除的作文，按 ID 降序排列
+	GetEssaysByUsername(username string) ([]models.Essay, error)
+	// GetEssay 获取用户的一篇作文（包括已软删除的），未找到时返回 nil
+	GetEssay(username string, essayID int64) (*models.Essay, error)
+	// DeleteEssay 软删除作文，version 为 0 时删除当前版本，作文不存在时返回 ErrEssayNotFound
+	DeleteEssay(username string, essayID int64, version int64) error
+	// GetEssayChanges 返回变更序号大于 since 的作文（包括已软删除的）和用户当前的最大变更序号，
+	// since 为 0 或大于当前最大序号（例如存储被重置过）时返回全部作文
+	GetEssayChanges(username string, since int64) ([]models.Essay, int64, error)
+}
+
+// 全局作文存储实例
+var essayStore EssayStore
+
+// InitEssayStore 根据 ESSAY_STORE 配置初始化作文存储
+//
+// dynamodb 和 file 分别使用 DynamoDB 和本地文件；auto（默认）在 ENABLE_DYNAMODB 为 true
+// 且 DynamoDB 初始化成功时使用 DynamoDB，否则使用本地文件，没有 AWS 也能同步和查看历史作文。
+func InitEssayStore(cfg *config.Config) error {
+	switch cfg.EssayStore {
+	case "dynamodb":
+		client, err := NewDynamoDBClient(cfg.AWSRegion, cfg.DynamoDBTable)
+		if err != nil {
+			return err
+		}
+		essayStore = client
+	case "file":
+		store, err := NewFileEssayStore(cfg.EssayDir)
+		if err != nil {
+			return err
+		}
+		essayStore = store
+	case "", "auto":
+		if cfg.EnableDynamoDB {
+			log.Println("初始化 DynamoDB 服务...")
+			client, err := NewDynamoDBClient(cfg.AWSRegion, cfg.DynamoDBTable)
+			if err == nil {
+				essayStore = client
+				return nil
+			}
+			log.Printf("DynamoDB 不可用，改用本地文件保存作文: %v", err)
+		}
+		store, err := NewFileEssayStore(cfg.EssayDir)
+		if err != nil {
+			return err
+		}
+		essayStore = store
+	default:
+		return fmt.Errorf("未知的作文存储: %s（可选 auto、dynamodb、file）", cfg.EssayStore)
+	}
+	return nil
+}
+
+// GetEssayStore 返回全局作文存储实例
+func GetEssayStore() EssayStore {
+	return essayStore
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"encoding/json"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileEssayStore 把作文保存在本地目录中，每个用户一个 JSON 文件，适合不使用 AWS 的自托管部署
+type FileEssayStore struct {
+	dir string
+
+	mutex sync.Mutex
+	users map[string][]models.Essay // 已读取的用户作文，按 ID 升序
+}
+
+// NewFileEssayStore 创建本地文件作文存储，目录不存在时自动创建
+func NewFileEssayStore(dir string) (*FileEssayStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建作文目录 %s 失败: %w", dir, err)
+	}
+	log.Printf("使用本地文件保存作文（目录: %s）", dir)
+	return &FileEssayStore{dir: dir, users: make(map[string][]models.Essay)}, nil
+}
+
+// SaveEssay 保存作文，ID 为 0 时分配该用户当前最大 ID（包括已删除的作文）加一
+func (s *FileEssayStore) SaveEssay(essay *models.Essay) error {
+	if essay.Username == "" {
+		return fmt.Errorf("用户名不能为空")
+	}
+	if essay.ID < 0 {
+		return fmt.Errorf("无效的作文 ID: %d", essay.ID)
+	}
+	if essay.UpdatedAt == "" {
+		essay.UpdatedAt = time.Now().Format(time.RFC3339)
+	}
+
+	// 分配 ID 和写入都在锁内完成，同一进程内的并发保存不会得到相同的 ID；
+	// 本地文件存储只支持单个服务实例
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	essays, err := s.loadLocked(essay.Username)
+	if err != nil {
+		return err
+	}
+
+	updated := append([]models.Essay{}, essays...)
+	if essay.ID == 0 {
+		essay.Version = 0
+		if len(updated) > 0 {
+			essay.ID = updated[len(updated)-1].ID + 1
+		} else {
+			essay.ID = 1
+		}
+	}
+	index := sort.Search(len(updated), func(i int) bool { return updated[i].ID >= essay.ID })
+	exists := index < len(updated) && updated[index].ID == essay.ID
+
+	// 已有作文只在版本号与客户端读到的一致时写入，避免覆盖其他设备的修改
+	var current int64
+	if exists {
+		current = updated[index].Version
+	}
+	if essay.Version != current {
+		if !exists {
+			return ErrEssayNotFound
+		}
+		conflict := updated[index]
+		return &VersionConflictError{Current: &conflict}
+	}
+	saved := *essay
+	saved.Version = current + 1
+	saved.Seq = maxSeq(updated) + 1
+
+	if exists {
+		updated[index] = saved
+	} else {
+		updated = append(updated, models.Essay{})
+		copy(updated[index+1:], updated[index:])
+		updated[index] = saved
+	}
+
+	if err := s.saveLocked(essay.Username, updated); err != nil {
+		return err
+	}
+	essay.Version = saved.Version
+	essay.Seq = saved.Seq
+	log.Printf("作文保存成功, 用户名: %s, ID: %d", essay.Username, essay.ID)
+	return nil
+}
+
+// GetEssaysByUsername 返回用户所有未删除的作文，按 ID 降序排列
+func (s *FileEssayStore) GetEssaysByUsername(username string) ([]models.Essay, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	essays, err := s.loadLocked(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var activeEssays []models.Essay
+	for i := len(essays) - 1; i >= 0; i-- {
+		if essays[i].DeletedAt == "" {
+			activeEssays = append(activeEssays, essays[i])
+		}
+	}
+	return activeEssays, nil
+}
+
+// GetEssay 获取用户的一篇作文，未找到时返回 nil
+func (s *FileEssayStore) GetEssay(username string, essayID int64) (*models.Essay, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	essays, err := s.loadLocked(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, essay := range essays {
+		if essay.ID == essayID {
+			return &essay, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteEssay 软删除作文，version 不为 0 时要求与存储中的版本一致
+func (s *FileEssayStore) DeleteEssay(username string, essayID int64, version int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	essays, err := s.loadLocked(username)
+	if err != nil {
+		return err
+	}
+	for i := range essays {
+		if essays[i].ID != essayID {
+			continue
+		}
+		if version != 0 && version != essays[i].Version {
+			conflict := essays[i]
+			return &VersionConflictError{Current: &conflict}
+		}
+		if essays[i].DeletedAt != "" {
+			return nil
+		}
+
+		updated := append([]models.Essay{}, essays...)
+		now := time.Now().Format(time.RFC3339)
+		updated[i].DeletedAt = now
+		updated[i].UpdatedAt = now
+		updated[i].Version++
+		updated[i].Seq = maxSeq(essays) + 1
+		if err := s.saveLocked(username, updated); err != nil {
+			return err
+		}
+		log.Printf("作文软删除成功, 用户名: %s, ID: %d", username, essayID)
+		return nil
+	}
+	return ErrEssayNotFound
+}
+
+// GetEssayChanges 返回变更序号大于 since 的作文（包括已软删除的）和用户当前的最大变更序号
+func (s *FileEssayStore) GetEssayChanges(username string, since int64) ([]models.Essay, int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	essays, err := s.loadLocked(username)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor := maxSeq(essays)
+	if since > cursor {
+		since = 0
+	}
+	changes := []models.Essay{}
+	for _, essay := range essays {
+		// 引入变更序号之前保存的作文序号为 0，只在全量同步时返回
+		if since == 0 || essay.Seq > since {
+			changes = append(changes, essay)
+		}
+	}
+	return changes, cursor, nil
+}
+
+// maxSeq 返回作文中最大的变更序号，软删除的作文仍然保留，序号不会因删除而变小
+func maxSeq(essays []models.Essay) int64 {
+	var seq int64
+	for _, essay := range essays {
+		seq = max(seq, essay.Seq)
+	}
+	return seq
+}
+
+// loadLocked 返回用户的全部作文，首次访问时从文件读取，调用方需持有锁且不能修改返回的切片
+func (s *FileEssayStore) loadLocked(username string) ([]models.Essay, error) {
+	if essays, exists := s.users[username]; exists {
+		return essays, nil
+	}
+
+	var essays []models.Essay
+	data, err := os.ReadFile(s.path(username))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取用户 %s 的作文失败: %w", username, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &essays); err != nil {
+			return nil, fmt.Errorf("解析用户 %s 的作文失败: %w", username, err)
+		}
+		sort.Slice(essays, func(i, j int) bool { return essays[i].ID < essays[j].ID })
+	}
+	s.users[username] = essays
+	return essays, nil
+}
+
+// saveLocked 写入用户的全部作文，成功后才更新内存中的副本
+func (s *FileEssayStore) saveLocked(username string, essays []models.Essay) error {
+	if err := writeJSONFile(s.path(username), essays); err != nil {
+		return fmt.Errorf("保存用户 %s 的作文失败: %w", username, err)
+	}
+	s.users[username] = essays
+	return nil
+}
+
+// path 返回用户的作文文件路径，用户名经过转义，不会逃出作文目录
+func (s *FileEssayStore) path(username string) string {
+	return filepath.Join(s.dir, url.PathEscape(username)+".json")
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrJobQueueFull 排队中的任务已达到上限
+var ErrJobQueueFull = errors.New("润色任务队列已满，请稍后再试")
+
+// ErrJobNotFound 任务不存在
+var ErrJobNotFound = errors.New("润色任务不存在")
+
+// JobQueue 异步润色任务队列
+//
+// 任务由固定数量的工作协程依次处理，所有任务保存在JSON文件中。服务重启后，
+// 排队中和被中断的任务会重新排队；已结束的任务保留 retention 时长后删除。
+type JobQueue struct {
+	path      string
+	retention time.Duration
+	ctx       context.Context // 关闭服务器时取消，正在处理的任务重新排队
+
+	mutex   sync.Mutex
+	jobs    map[string]*models.PolishJob
+	cancels map[string]context.CancelFunc // 正在处理的任务
+	pending chan string
+	workers sync.WaitGroup
+}
+
+// 全局任务队列实例
+var jobQueue *JobQueue
+
+// InitJobQueue 加载已保存的任务并启动 workers 个工作协程，最多允许 maxQueued 个任务排队
+func InitJobQueue(ctx context.Context, path string, workers, maxQueued int, retention time.Duration) error {
+	queue := &JobQueue{
+		path:      path,
+		retention: retention,
+		ctx:       ctx,
+		jobs:      make(map[string]*models.PolishJob),
+		cancels:   make(map[string]context.CancelFunc),
+		pending:   make(chan string, maxQueued),
+	}
+	if err := queue.load(); err != nil {
+		return err
+	}
+
+	for i := 0; i < workers; i++ {
+		queue.workers.Add(1)
+		go queue.work()
+	}
+	jobQueue = queue
+	return nil
+}
+
+// GetJobQueue 返回全局任务队列实例
+func GetJobQueue() *JobQueue {
+	return jobQueue
+}
+
+// Wait 等待所有工作协程退出，在取消 InitJobQueue 的 ctx 之后调用
+func (q *JobQueue) Wait() {
+	q.workers.Wait()
+}
+
+// Submit 提交润色任务，req 需已完成参数校验
+func (q *JobQueue) Submit(req *PolishRequest) (*models.PolishJob, error) {
+	job := &models.PolishJob{
+		ID:        newJobID(),
+		Username:  req.Username,
+		Status:    models.PolishJobQueued,
+		Request:   req.EssayRequest,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	select {
+	case q.pending <- job.ID:
+	default:
+		return nil, ErrJobQueueFull
+	}
+	q.jobs[job.ID] = job
+	q.saveLocked()
+
+	log.Printf("润色任务 %s 已排队, 用户: %s", job.ID, job.Username)
+	copied := *job
+	return &copied, nil
+}
+
+// Get 返回任务的副本
+func (q *JobQueue) Get(id string) (*models.PolishJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, exists := q.jobs[id]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// Cancel 取消排队中或正在处理的任务，已结束的任务保持原状态
+func (q *JobQueue) Cancel(id string) (*models.PolishJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, exists := q.jobs[id]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+
+	if !job.IsFinished() {
+		// 正在处理的任务中止AI调用，工作协程看到取消状态后不再覆盖结果
+		if cancel, running := q.cancels[id]; running {
+			cancel()
+		}
+		job.Status = models.PolishJobCanceled
+		job.FinishedAt = time.Now().Format(time.RFC3339)
+		q.saveLocked()
+		log.Printf("润色任务 %s 已取消", id)
+	}
+
+	copied := *job
+	return &copied, nil
+}
+
+// work 工作协程：依次取出排队的任务并润色
+func (q *JobQueue) work() {
+	defer q.workers.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case id := <-q.pending:
+			q.run(id)
+		}
+	}
+}
+
+// run 处理一个任务
+func (q *JobQueue) run(id string) {
+	q.mutex.Lock()
+	job, exists := q.jobs[id]
+	if !exists || job.Status != models.PolishJobQueued {
+		// 排队期间被取消
+		q.mutex.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(q.ctx)
+	defer cancel()
+	q.cancels[id] = cancel
+	job.Status = models.PolishJobRunning
+	job.StartedAt = time.Now().Format(time.RFC3339)
+	req := NewPolishRequest(job.Request)
+	req.Structured = true
+	req.Username = job.Username
+	q.saveLocked()
+	q.mutex.Unlock()
+
+	log.Printf("开始处理润色任务 %s", id)
+	result, err := GetAIService().PolishEssay(ctx, req)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.cancels, id)
+
+	switch {
+	case job.Status == models.PolishJobCanceled:
+		// 用户已取消，保持取消状态
+	case err != nil && q.ctx.Err() != nil:
+		// 服务器关闭导致中断，重启后重新处理
+		job.Status = models.PolishJobQueued
+		job.StartedAt = ""
+		log.Printf("服务器关闭，润色任务 %s 将在重启后重新处理", id)
+	case err != nil:
+		job.Status = models.PolishJobFailed
+		job.Error = err.Error()
+		job.FinishedAt = time.Now().Format(time.RFC3339)
+		log.Printf("润色任务 %s 失败: %v", id, err)
+	default:
+		job.Status = models.PolishJobSucceeded
+		job.Result = &models.PolishJobResult{
+			PolishedContent: result.Content,
+			Provider:        result.Provider,
+			Feedback:        result.Feedback,
+			Changes:         DiffText(job.Request.Content, result.Content),
+			Usage:           result.Usage,
+			Cached:          result.Cached,
+		}
+		job.FinishedAt = time.Now().Format(time.RFC3339)
+		log.Printf("润色任务 %s 已完成, 提供方: %s", id, result.Provider)
+	}
+	q.saveLocked()
+}
+
+// load 加载已保存的任务，未结束的任务重新排队
+func (q *JobQueue) load() error {
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取润色任务文件 %s 失败: %w", q.path, err)
+	}
+
+	var jobs []*models.PolishJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("解析润色任务文件 %s 失败: %w", q.path, err)
+	}
+
+	requeued := 0
+	for _, job := range jobs {
+		q.jobs[job.ID] = job
+		if job.IsFinished() {
+			continue
+		}
+
+		job.Status = models.PolishJobQueued
+		job.StartedAt = ""
+		select {
+		case q.pending <- job.ID:
+			requeued++
+		default:
+			job.Status = models.PolishJobFailed
+			job.Error = ErrJobQueueFull.Error()
+			job.FinishedAt = time.Now().Format(time.RFC3339)
+		}
+	}
+	log.Printf("已加载 %d 个润色任务，其中 %d 个重新排队", len(jobs), requeued)
+	return nil
+}
+
+// saveLocked 删除过期的已结束任务并保存所有任务，调用方需持有锁
+func (q *JobQueue) saveLocked() {
+	expired := time.Now().Add(-q.retention).Format(time.RFC3339)
+	jobs := make([]*models.PolishJob, 0, len(q.jobs))
+	for id, job := range q.jobs {
+		if job.IsFinished() && job.FinishedAt < expired {
+			delete(q.jobs, id)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := writeJSONFile(q.path, jobs); err != nil {
+		log.Printf("保存润色任务失败: %v", err)
+	}
+}
+
+// writeJSONFile 把数据写入临时文件后替换目标文件，避免写入中途退出导致文件损坏
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// newJobID 生成随机的任务ID
+func newJobID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
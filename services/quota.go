@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaService 按用户和角色限制每天、每月的润色请求数和token用量
+//
+// 请求数由配额服务自己计数并保存到状态文件，token用量来自 UsageTracker。
+// 未登录用户按客户端IP分别计算请求数（见 QuotaKey）；用量统计不区分未登录用户，token用量仍共用一个配额。
+type QuotaService struct {
+	config    models.QuotaConfig
+	statePath string
+
+	mutex    sync.Mutex
+	requests map[string]map[string]int // 配额键 -> 日期 -> 请求数
+}
+
+// anonymousKeyPrefix 未登录用户配额键的前缀，后接客户端IP
+const anonymousKeyPrefix = "anon:"
+
+// QuotaKey 返回计算请求配额使用的键，登录用户为用户名，未登录用户为 "anon:" 加客户端IP
+func QuotaKey(username, clientIP string) string {
+	if username == "" {
+		return anonymousKeyPrefix + clientIP
+	}
+	return username
+}
+
+// quotaUsername 返回配额键对应的用户名，未登录用户为空
+func quotaUsername(key string) string {
+	if strings.HasPrefix(key, anonymousKeyPrefix) {
+		return ""
+	}
+	return key
+}
+
+// 全局配额服务实例，未配置配额时为 nil
+var quotaService *QuotaService
+
+// InitQuotaService 加载配额配置和请求计数，配置文件不存在时不限制配额
+func InitQuotaService(configPath, statePath string) error {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		log.Printf("配额配置文件 %s 不存在，不限制润色配额", configPath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取配额配置文件 %s 失败: %w", configPath, err)
+	}
+
+	service := &QuotaService{
+		statePath: statePath,
+		requests:  make(map[string]map[string]int),
+	}
+	if err := json.Unmarshal(data, &service.config); err != nil {
+		return fmt.Errorf("解析配额配置文件 %s 失败: %w", configPath, err)
+	}
+
+	state, err := os.ReadFile(statePath)
+	if err == nil {
+		if err := json.Unmarshal(state, &service.requests); err != nil {
+			return fmt.Errorf("解析配额状态文件 %s 失败: %w", statePath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("读取配额状态文件 %s 失败: %w", statePath, err)
+	}
+
+	quotaService = service
+	log.Printf("已加载配额配置: %d 个角色, %d 个用户", len(service.config.Roles), len(service.config.Users))
+	return nil
+}
+
+// GetQuotaService 返回全局配额服务实例，未启用配额时返回 nil
+func GetQuotaService() *QuotaService {
+	return quotaService
+}
+
+// limitsFor 返回用户适用的配额限制
+func (s *QuotaService) limitsFor(key, role string) models.QuotaLimits {
+	username := quotaUsername(key)
+	if limits, exists := s.config.Users[username]; exists && username != "" {
+		return limits
+	}
+	return s.config.Roles[role]
+}
+
+// Acquire 检查配额并计入一次请求，key 由 QuotaKey 生成
+//
+// 任一项配额已用完时不计入，返回 false；返回的状态列表包含所有已配置的限制项（已计入本次请求）。
+func (s *QuotaService) Acquire(key, role string) (bool, []models.QuotaStatus) {
+	limits := s.limitsFor(key, role)
+	username := quotaUsername(key)
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	dayReset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	monthReset := monthStart.AddDate(0, 1, 0)
+
+	tokensToday, tokensThisMonth := 0, 0
+	if tracker := GetUsageTracker(); tracker != nil && (limits.TokensPerDay > 0 || limits.TokensPerMonth > 0) {
+		tokensToday = tracker.TokensUsed(username, today, today)
+		tokensThisMonth = tracker.TokensUsed(username, monthStart.Format("2006-01-02"), today)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	requestsToday := s.requests[key][today]
+	requestsThisMonth := 0
+	for date, count := range s.requests[key] {
+		if date >= monthStart.Format("2006-01-02") {
+			requestsThisMonth += count
+		}
+	}
+
+	statuses := []models.QuotaStatus{}
+	allowed := true
+	check := func(name string, limit, used int, resetAt time.Time) {
+		if limit <= 0 {
+			return
+		}
+		if used >= limit {
+			allowed = false
+		}
+		statuses = append(statuses, models.QuotaStatus{
+			Name:      name,
+			Limit:     limit,
+			Used:      used,
+			Remaining: max(0, limit-used),
+			ResetAt:   resetAt.Format(time.RFC3339),
+		})
+	}
+	check("requestsPerDay", limits.RequestsPerDay, requestsToday, dayReset)
+	check("requestsPerMonth", limits.RequestsPerMonth, requestsThisMonth, monthReset)
+	check("tokensPerDay", limits.TokensPerDay, tokensToday, dayReset)
+	check("tokensPerMonth", limits.TokensPerMonth, tokensThisMonth, monthReset)
+	if !allowed {
+		return false, statuses
+	}
+
+	// 计入本次请求
+	if s.requests[key] == nil {
+		s.requests[key] = make(map[string]int)
+	}
+	s.requests[key][today]++
+	for i := range statuses {
+		if statuses[i].Name == "requestsPerDay" || statuses[i].Name == "requestsPerMonth" {
+			statuses[i].Used++
+			statuses[i].Remaining = max(0, statuses[i].Limit-statuses[i].Used)
+		}
+	}
+
+	s.prune(monthStart.Format("2006-01-02"))
+	if err := s.save(); err != nil {
+		log.Printf("保存配额状态失败: %v", err)
+	}
+	return true, statuses
+}
+
+// Release 退还 Acquire 计入的一次请求，用于请求未通过校验、没有调用AI服务的情况
+func (s *QuotaService) Release(key string) {
+	today := time.Now().Format("2006-01-02")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.requests[key][today] <= 0 {
+		return
+	}
+	s.requests[key][today]--
+	if err := s.save(); err != nil {
+		log.Printf("保存配额状态失败: %v", err)
+	}
+}
+
+// prune 删除本月之前的请求计数，调用方需持有锁
+func (s *QuotaService) prune(monthStart string) {
+	for key, days := range s.requests {
+		for date := range days {
+			if date < monthStart {
+				delete(days, date)
+			}
+		}
+		if len(days) == 0 {
+			delete(s.requests, key)
+		}
+	}
+}
+
+// save 保存请求计数，调用方需持有锁
+func (s *QuotaService) save() error {
+	return writeJSONFile(s.statePath, s.requests)
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"essay-go/config"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 外部AI调用的重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最多尝试次数（含第一次），小于 1 时按 1 处理
+	BaseDelay   time.Duration // 第一次重试前的基础等待时间，之后按指数增长
+	MaxDelay    time.Duration // 单次等待的上限，Retry-After 超过该值时放弃重试
+}
+
+// retryPolicyFromConfig 从配置构造重试策略
+func retryPolicyFromConfig(cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: cfg.AIRetryMaxAttempts,
+		BaseDelay:   cfg.AIRetryBaseDelay,
+		MaxDelay:    cfg.AIRetryMaxDelay,
+	}
+}
+
+// backoff 返回第 attempt 次重试前的等待时间（指数退避 + 全抖动）
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// doWithRetry 发送请求，遇到网络错误、429 或 5xx 时按策略重试
+//
+// 请求体通过 req.GetBody 在每次重试时重新生成（http.NewRequest 对 bytes.Buffer 会自动设置）。
+// 最后一次尝试仍返回可重试状态码时，原样返回响应，由调用方解析错误内容。
+func doWithRetry(client *http.Client, req *http.Request, policy RetryPolicy) (*http.Response, error) {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("重建请求体失败: %w", err)
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := client.Do(attemptReq)
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		// 调用方已取消或超时，不再重试
+		if attempt >= maxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		delay := policy.backoff(attempt)
+		if err != nil {
+			fmt.Printf("[doWithRetry] 第 %d 次请求失败: %v\n", attempt, err)
+		} else {
+			fmt.Printf("[doWithRetry] 第 %d 次请求返回状态码 %d\n", attempt, resp.StatusCode)
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > policy.MaxDelay {
+					// 服务端要求等待的时间过长，直接返回，交给回退链切换提供方
+					fmt.Printf("[doWithRetry] Retry-After %v 超过最大等待时间 %v，不再重试\n", retryAfter, policy.MaxDelay)
+					return resp, nil
+				}
+				if retryAfter > delay {
+					delay = retryAfter
+				}
+			}
+			// 丢弃响应体以便复用连接
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		fmt.Printf("[doWithRetry] %v 后进行第 %d 次尝试\n", delay, attempt+1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"为空", "", 0, false},
+		{"零秒", "0", 0, true},
+		{"秒数", "120", 120 * time.Second, true},
+		{"负数", "-1", 0, false},
+		{"无法解析", "soon", 0, false},
+		{"已过去的日期", "Sun, 06 Nov 1994 08:49:37 GMT", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, 期望 %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	// HTTP日期只精确到秒
+	value := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	got, ok := parseRetryAfter(value)
+	if !ok || got < 28*time.Second || got > 30*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, %v, 期望约 30s", value, got, ok)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		limit   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second}, // 移位溢出时按 MaxDelay 处理
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if delay := policy.backoff(tt.attempt); delay <= 0 || delay > tt.limit {
+				t.Fatalf("backoff(%d) = %v, 期望在 (0, %v] 之间", tt.attempt, delay, tt.limit)
+			}
+		}
+	}
+
+	if delay := (RetryPolicy{}).backoff(1); delay != 0 {
+		t.Errorf("未配置等待时间时 backoff(1) = %v, 期望 0", delay)
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	tests := []struct {
+		name       string
+		statuses   []int  // 依次返回的状态码，用完后返回最后一个
+		retryAfter string // 失败响应的 Retry-After
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"成功不重试", []int{200}, "", 200, 1},
+		{"4xx不重试", []int{400}, "", 400, 1},
+		{"5xx后成功", []int{503, 500, 200}, "", 200, 3},
+		{"429后成功", []int{429, 200}, "0", 200, 2},
+		{"超过最多尝试次数", []int{502}, "", 502, 3},
+		{"Retry-After超过最大等待时间", []int{429, 200}, "60", 429, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// 每次重试都要重新发送完整的请求体
+				if body, _ := io.ReadAll(r.Body); string(body) != "作文" {
+					t.Errorf("第 %d 次请求的请求体为 %q", calls.Load()+1, body)
+				}
+				n := int(calls.Add(1))
+				status := tt.statuses[min(n, len(tt.statuses))-1]
+				if status != http.StatusOK && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("作文"))
+			resp, err := doWithRetry(server.Client(), req, policy)
+			if err != nil {
+				t.Fatalf("doWithRetry 失败: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("状态码 = %d, 期望 %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("请求了 %d 次, 期望 %d 次", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// 全局评分标准
+var rubric *models.Rubric
+
+// DefaultRubric 返回内置的默认评分标准（内容、结构、语言、创意、书写规范）
+func DefaultRubric() *models.Rubric {
+	return &models.Rubric{
+		Name: "默认作文评分标准",
+		Criteria: []models.RubricCriterion{
+			{Key: "content", Name: "内容", Description: "中心明确，内容具体充实，选材真实贴切，能表达真情实感。", MaxScore: 30},
+			{Key: "structure", Name: "结构", Description: "条理清楚，段落分明，开头结尾呼应，过渡自然。", MaxScore: 20},
+			{Key: "language", Name: "语言", Description: "语句通顺，用词准确生动，能恰当运用修辞手法。", MaxScore: 25},
+			{Key: "creativity", Name: "创意", Description: "立意新颖，视角独特，有自己的想法和感受。", MaxScore: 15},
+			{Key: "mechanics", Name: "书写规范", Description: "没有错别字，标点符号使用正确，格式规范。", MaxScore: 10},
+		},
+	}
+}
+
+// InitRubric 从JSON文件加载评分标准，文件不存在时使用内置的默认标准
+func InitRubric(path string) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("评分标准文件 %s 不存在，使用默认评分标准", path)
+		rubric = DefaultRubric()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取评分标准文件失败: %w", err)
+	}
+
+	var loaded models.Rubric
+	if err := json.Unmarshal(content, &loaded); err != nil {
+		return fmt.Errorf("解析评分标准文件失败: %w", err)
+	}
+	if err := validateRubric(&loaded); err != nil {
+		return fmt.Errorf("评分标准 %s 无效: %w", path, err)
+	}
+
+	rubric = &loaded
+	log.Printf("已加载评分标准 %s，共 %d 项", loaded.Name, len(loaded.Criteria))
+	return nil
+}
+
+// GetRubric 返回当前的评分标准
+func GetRubric() *models.Rubric {
+	if rubric == nil {
+		return DefaultRubric()
+	}
+	return rubric
+}
+
+// validateRubric 检查评分项的 key 不重复且满分为正数
+func validateRubric(r *models.Rubric) error {
+	if len(r.Criteria) == 0 {
+		return errors.New("至少需要一个评分项")
+	}
+	seen := make(map[string]bool)
+	for _, criterion := range r.Criteria {
+		if criterion.Key == "" {
+			return errors.New("评分项缺少 key")
+		}
+		if seen[criterion.Key] {
+			return fmt.Errorf("评分项 %s 重复", criterion.Key)
+		}
+		if criterion.MaxScore <= 0 {
+			return fmt.Errorf("评分项 %s 的满分必须大于 0", criterion.Key)
+		}
+		seen[criterion.Key] = true
+	}
+	return nil
+}
+
+// parseScore 解析模型返回的评分JSON，并按评分标准补全名称、限制分数范围、计算总分
+func parseScore(raw string, r *models.Rubric) (*models.EssayScore, error) {
+	var parsed struct {
+		Criteria []struct {
+			Key           string  `json:"key"`
+			Score         float64 `json:"score"`
+			Justification string  `json:"justification"`
+		} `json:"criteria"`
+		Comment string `json:"comment"`
+	}
+	if err := unmarshalModelJSON(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("解析评分结果失败: %w", err)
+	}
+
+	byKey := make(map[string]int, len(parsed.Criteria))
+	for i, item := range parsed.Criteria {
+		byKey[item.Key] = i
+	}
+
+	score := &models.EssayScore{
+		Comment:  parsed.Comment,
+		Rubric:   r.Name,
+		ScoredAt: time.Now().Format(time.RFC3339),
+	}
+	for _, criterion := range r.Criteria {
+		index, exists := byKey[criterion.Key]
+		if !exists {
+			return nil, fmt.Errorf("评分结果中缺少评分项 %s", criterion.Key)
+		}
+		item := parsed.Criteria[index]
+
+		points := int(item.Score + 0.5)
+		if points < 0 {
+			points = 0
+		}
+		if points > criterion.MaxScore {
+			points = criterion.MaxScore
+		}
+
+		score.Criteria = append(score.Criteria, models.CriterionScore{
+			Key:           criterion.Key,
+			Name:          criterion.Name,
+			Score:         points,
+			MaxScore:      criterion.MaxScore,
+			Justification: item.Justification,
+		})
+		score.Total += points
+		score.MaxTotal += criterion.MaxScore
+	}
+	return score, nil
+}
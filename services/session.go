@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 交互式润色会话的限制，避免对话历史超出模型的上下文长度
+const (
+	maxSessionTurns      = 10  // 一个会话最多的追问次数
+	maxInstructionLength = 500 // 单条修改要求的最大字数
+)
+
+// ErrSessionNotStarted 会话还没有润色结果，不能追问
+var ErrSessionNotStarted = errors.New("请先提交作文开始润色")
+
+// ErrSessionTooLong 追问次数达到上限，需要重新开始会话
+var ErrSessionTooLong = fmt.Errorf("修改次数已达上限（%d 次），请重新提交作文", maxSessionTurns)
+
+// PolishSession 交互式润色会话
+//
+// 首次润色之后，用户可以针对结果继续提出修改要求。会话保存完整的对话历史，
+// 每次追问都把首次的润色提示词和之后的全部对话一起发给AI服务。会话不是并发安全的，
+// 同一时间只能进行一次润色。
+type PolishSession struct {
+	service AIService
+	base    *PolishRequest
+	history []ChatMessage
+}
+
+// NewPolishSession 创建使用指定AI服务的交互式润色会话
+func NewPolishSession(service AIService) *PolishSession {
+	return &PolishSession{service: service}
+}
+
+// Turns 返回已经进行的追问次数
+func (s *PolishSession) Turns() int {
+	if len(s.history) == 0 {
+		return 0
+	}
+	return (len(s.history) - 1) / 2
+}
+
+// Polished 返回当前的润色结果，会话未开始时为空
+func (s *PolishSession) Polished() string {
+	return lastAssistantMessage(s.history, "")
+}
+
+// Start 流式润色作文并开始新的会话，失败时保留原来的会话
+func (s *PolishSession) Start(ctx context.Context, req *PolishRequest, onDelta func(delta string) error) (*PolishResult, error) {
+	req.Structured = false
+	req.History = nil
+	result, err := s.service.PolishEssayStream(ctx, req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	s.base = req
+	s.history = []ChatMessage{{Role: "assistant", Content: result.Content}}
+	return result, nil
+}
+
+// Resume 以已有的润色结果开始会话，不调用AI服务
+func (s *PolishSession) Resume(req *PolishRequest, polished string) {
+	req.Structured = false
+	req.History = nil
+	s.base = req
+	s.history = []ChatMessage{{Role: "assistant", Content: polished}}
+}
+
+// CheckFollowUp 检查能否按修改要求继续修改，返回去掉首尾空白的修改要求，
+// 调用方可以在计入配额之前先检查
+func (s *PolishSession) CheckFollowUp(instruction string) (string, error) {
+	if s.base == nil {
+		return "", ErrSessionNotStarted
+	}
+	if s.Turns() >= maxSessionTurns {
+		return "", ErrSessionTooLong
+	}
+	instruction = strings.TrimSpace(instruction)
+	if instruction == "" {
+		return "", errors.New("修改要求不能为空")
+	}
+	if utf8.RuneCountInString(instruction) > maxInstructionLength {
+		return "", fmt.Errorf("修改要求不能超过 %d 字", maxInstructionLength)
+	}
+	return instruction, nil
+}
+
+// FollowUp 按用户的修改要求继续修改上一次的结果，失败时对话历史保持不变
+func (s *PolishSession) FollowUp(ctx context.Context, instruction string, onDelta func(delta string) error) (*PolishResult, error) {
+	instruction, err := s.CheckFollowUp(instruction)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := GetPromptStore().RenderFollowUp(s.base, instruction)
+	if err != nil {
+		return nil, err
+	}
+
+	req := *s.base
+	req.History = append(append([]ChatMessage{}, s.history...), ChatMessage{Role: "user", Content: message})
+	result, err := s.service.PolishEssayStream(ctx, &req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	s.history = append(req.History, ChatMessage{Role: "assistant", Content: result.Content})
+	return result, nil
+}
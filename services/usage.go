@@ -0,0 +1,252 @@
+package services
+
+import (
+	"encoding/json"
+	"essay-go/models"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelPrice 模型的token价格，单位为元/百万token
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// usageKey 用量记录的聚合维度
+type usageKey struct {
+	username string
+	date     string
+	provider string
+	model    string
+}
+
+// UsageTracker 按用户、日期和模型累计AI调用的token用量，并保存到JSON文件
+type UsageTracker struct {
+	path   string
+	prices map[string]ModelPrice
+
+	mutex   sync.Mutex
+	records map[usageKey]*models.UsageRecord
+}
+
+// UsageFilter 查询用量的条件，空字段表示不限制
+type UsageFilter struct {
+	Username string
+	Model    string
+	From     string // 起始日期（含），格式为 2006-01-02
+	To       string // 结束日期（含）
+}
+
+// 全局用量统计实例
+var usageTracker *UsageTracker
+
+// InitUsageTracker 从文件加载历史用量，prices 的格式见 parseModelPrices
+func InitUsageTracker(path, prices string) error {
+	parsedPrices, err := parseModelPrices(prices)
+	if err != nil {
+		return err
+	}
+
+	tracker := &UsageTracker{
+		path:    path,
+		prices:  parsedPrices,
+		records: make(map[usageKey]*models.UsageRecord),
+	}
+	if err := tracker.load(); err != nil {
+		return err
+	}
+	usageTracker = tracker
+	return nil
+}
+
+// GetUsageTracker 返回全局用量统计实例
+func GetUsageTracker() *UsageTracker {
+	return usageTracker
+}
+
+// recordUsage 记录一次AI调用的用量，用量统计未初始化或没有用量时忽略
+func recordUsage(username, provider, model string, usage models.TokenUsage) {
+	if usageTracker == nil || usage.TotalTokens == 0 && usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		return
+	}
+	usageTracker.Record(username, provider, model, usage)
+}
+
+// Record 累加一次调用的用量并保存
+func (t *UsageTracker) Record(username, provider, model string, usage models.TokenUsage) {
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	key := usageKey{
+		username: username,
+		date:     time.Now().Format("2006-01-02"),
+		provider: provider,
+		model:    model,
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	record, exists := t.records[key]
+	if !exists {
+		record = &models.UsageRecord{Username: key.username, Date: key.date, Provider: key.provider, Model: key.model}
+		t.records[key] = record
+	}
+	record.Requests++
+	record.Add(usage)
+	record.Cost += t.cost(model, usage)
+
+	if err := t.save(); err != nil {
+		log.Printf("保存用量统计失败: %v", err)
+	}
+}
+
+// Query 返回符合条件的用量记录，按日期、用户名、模型排序
+func (t *UsageTracker) Query(filter UsageFilter) []models.UsageRecord {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	records := []models.UsageRecord{}
+	for _, record := range t.records {
+		if filter.Username != "" && record.Username != filter.Username {
+			continue
+		}
+		if filter.Model != "" && record.Model != filter.Model {
+			continue
+		}
+		if filter.From != "" && record.Date < filter.From {
+			continue
+		}
+		if filter.To != "" && record.Date > filter.To {
+			continue
+		}
+		records = append(records, *record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Date != records[j].Date {
+			return records[i].Date < records[j].Date
+		}
+		if records[i].Username != records[j].Username {
+			return records[i].Username < records[j].Username
+		}
+		return records[i].Model < records[j].Model
+	})
+	return records
+}
+
+// TokensUsed 返回用户在 [from, to] 日期范围内消耗的token总数
+func (t *UsageTracker) TokensUsed(username, from, to string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	total := 0
+	for key, record := range t.records {
+		if key.username == username && key.date >= from && key.date <= to {
+			total += record.TotalTokens
+		}
+	}
+	return total
+}
+
+// SummarizeUsage 按 keyOf 返回的维度汇总用量，结果按 key 排序
+func SummarizeUsage(records []models.UsageRecord, keyOf func(record models.UsageRecord) string) []models.UsageSummary {
+	summaries := make(map[string]*models.UsageSummary)
+	for _, record := range records {
+		key := keyOf(record)
+		summary, exists := summaries[key]
+		if !exists {
+			summary = &models.UsageSummary{Key: key}
+			summaries[key] = summary
+		}
+		summary.Requests += record.Requests
+		summary.Add(record.TokenUsage)
+		summary.Cost += record.Cost
+	}
+
+	result := make([]models.UsageSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// cost 按模型价格计算费用，未配置价格时为 0
+func (t *UsageTracker) cost(model string, usage models.TokenUsage) float64 {
+	price, exists := t.prices[model]
+	if !exists {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// load 从文件加载历史用量，文件不存在时从空记录开始
+func (t *UsageTracker) load() error {
+	data, err := os.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取用量统计文件 %s 失败: %w", t.path, err)
+	}
+
+	var records []models.UsageRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("解析用量统计文件 %s 失败: %w", t.path, err)
+	}
+	for i := range records {
+		record := records[i]
+		t.records[usageKey{record.Username, record.Date, record.Provider, record.Model}] = &record
+	}
+	log.Printf("已加载 %d 条用量统计记录", len(records))
+	return nil
+}
+
+// save 保存全部用量，调用方需持有锁
+func (t *UsageTracker) save() error {
+	records := make([]*models.UsageRecord, 0, len(t.records))
+	for _, record := range t.records {
+		records = append(records, record)
+	}
+	return writeJSONFile(t.path, records)
+}
+
+// parseModelPrices 解析模型价格配置
+//
+// 格式为逗号分隔的 "模型=输入价格/输出价格"，单位为元/百万token，例如 "deepseek-chat=2/8,gpt-4o-mini=1.1/4.4"。
+func parseModelPrices(spec string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		model, value, ok := strings.Cut(item, "=")
+		promptPrice, completionPrice, ok2 := strings.Cut(value, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("模型价格配置 %q 的格式应为 模型=输入价格/输出价格", item)
+		}
+		prompt, err := strconv.ParseFloat(strings.TrimSpace(promptPrice), 64)
+		if err != nil {
+			return nil, fmt.Errorf("模型价格配置 %q 中的输入价格无效", item)
+		}
+		completion, err := strconv.ParseFloat(strings.TrimSpace(completionPrice), 64)
+		if err != nil {
+			return nil, fmt.Errorf("模型价格配置 %q 中的输出价格无效", item)
+		}
+		prices[strings.TrimSpace(model)] = ModelPrice{Prompt: prompt, Completion: completion}
+	}
+	return prices, nil
+}